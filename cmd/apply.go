@@ -1,24 +1,95 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 var (
 	applyCmd = &cobra.Command{
-		Use:   "apply <overlay> [ <spec> ]",
-		Short: "Given an overlay, it will apply it to the spec. If omitted, spec will be loaded via extends (only from local file system).",
-		Args:  cobra.RangeArgs(1, 2),
-		Run:   RunApply,
+		Use:         "apply <overlay> [ <spec> ]",
+		Short:       "Given an overlay, it will apply it to the spec. If omitted, spec will be loaded via extends (only from local file system).",
+		Args:        cobra.RangeArgs(1, 2),
+		Run:         RunApply,
+		Annotations: map[string]string{stdinFlags: "vars-file"},
 	}
+
+	applyDanglingRefs string
+	applyPrune        bool
+	applyPruneKeep    []string
+	applyValidateSpec bool
+	applySourceMap    string
+	applyProvenance   bool
+	applyMinimalDiff  bool
+	applyBundle       bool
+	applyWriteBack    bool
+	applyOutDir       string
+	applyVars         []string
+	applyVarsFile     string
+	applyEnvVars      []string
+	applyInterpolate  bool
+	applyStrict       bool
 )
 
+func init() {
+	applyCmd.Flags().StringVar(&applyDanglingRefs, "dangling-refs", "ignore", "what to do with $refs the overlay leaves dangling: ignore, report, remove or rewrite")
+	applyCmd.Flags().BoolVar(&applyPrune, "prune", false, "remove components that are no longer referenced once the overlay has been applied")
+	applyCmd.Flags().StringSliceVar(&applyPruneKeep, "prune-keep", nil, "components to keep when pruning, as <type>/<name> patterns such as schemas/Error")
+	applyCmd.Flags().BoolVar(&applyValidateSpec, "validate-spec", false, "fail if the result is not a valid OpenAPI 3.0 or 3.1 document, naming the actions that broke it")
+	applyCmd.Flags().StringVar(&applySourceMap, "source-map", "", "write a JSON source map of the nodes each action created or changed to the given file")
+	applyCmd.Flags().BoolVar(&applyProvenance, "provenance-comments", false, "add a comment naming the action responsible to each node an action created or changed")
+	applyCmd.Flags().BoolVar(&applyMinimalDiff, "minimal-diff", false, "keep the original text of everything the overlay did not change, including comments, quoting and blank lines")
+	applyCmd.Flags().BoolVar(&applyBundle, "bundle", false, "resolve $refs to other files into the spec before applying, so the overlay can target them")
+	applyCmd.Flags().BoolVar(&applyWriteBack, "write-back", false, "like --bundle, but write the changes back to the files they came from instead of stdout")
+	applyCmd.Flags().StringVar(&applyOutDir, "out-dir", "", "like --write-back, but write every file of the spec to the given directory, keeping their layout")
+	applyCmd.Flags().StringArrayVar(&applyVars, "var", nil, "set a variable for x-when conditions and interpolation, as name=value; can be repeated")
+	applyCmd.Flags().StringVar(&applyVarsFile, "vars-file", "", "load variables from a YAML or JSON mapping of names to values")
+	applyCmd.Flags().StringArrayVar(&applyEnvVars, "env-var", nil, "make the named environment variable available as a variable, if it is set; can be repeated")
+	applyCmd.Flags().BoolVar(&applyStrict, "strict", false, "fail if any action's target matches nothing, unless the action sets x-on-no-match")
+	applyCmd.Flags().BoolVar(&applyInterpolate, "interpolate", false, "replace ${name} in the scalars of updates with the value of the variable, failing if it is not set")
+}
+
 func RunApply(cmd *cobra.Command, args []string) {
 	overlayFile := args[0]
 
+	policy, err := overlay.ParseDanglingRefPolicy(applyDanglingRefs)
+	if err != nil {
+		Die(err)
+	}
+
+	vars := map[string]string{}
+	if applyVarsFile != "" {
+		vars, err = loader.LoadVars(applyVarsFile)
+		if err != nil {
+			Die(err)
+		}
+	}
+	for _, name := range applyEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			vars[name] = value
+		}
+	}
+	for _, v := range applyVars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			Dief("Invalid --var %q: expected name=value", v)
+		}
+		vars[name] = value
+	}
+	// without any variables, actions with x-when are skipped rather than
+	// failing, as they are when the library is given none
+	if len(vars) == 0 {
+		vars = nil
+	}
+
 	o, err := loader.LoadOverlay(overlayFile)
 	if err != nil {
 		Die(err)
@@ -28,18 +99,132 @@ func RunApply(cmd *cobra.Command, args []string) {
 	if len(args) > 1 {
 		specFile = args[1]
 	}
-	ys, specFile, err := loader.LoadEitherSpecification(specFile, o)
+	// every document of a multi-document spec is loaded and written back
+	// out, but the overlay only applies to one of them
+	var (
+		docs     []*yaml.Node
+		textDocs []*overlay.Document
+		bundle   *loader.Bundle
+	)
+	if applyBundle || applyWriteBack || applyOutDir != "" {
+		if applyMinimalDiff {
+			Dief("--minimal-diff cannot be combined with --bundle, --write-back or --out-dir")
+		}
+		if specFile == "" {
+			specFile, err = loader.GetOverlayExtendsPath(o)
+		}
+		if err == nil {
+			bundle, err = loader.LoadBundle(specFile)
+		}
+		if err == nil {
+			docs = []*yaml.Node{bundle.Root}
+		}
+	} else if applyMinimalDiff {
+		textDocs, specFile, err = loader.LoadEitherDocuments(specFile, o)
+		for _, doc := range textDocs {
+			docs = append(docs, doc.Root)
+		}
+	} else {
+		docs, specFile, err = loader.LoadEitherSpecificationDocuments(specFile, o)
+	}
 	if err != nil {
 		Die(err)
 	}
-
-	err = o.ApplyTo(ys)
+	index, err := o.SelectDocument(len(docs))
 	if err != nil {
 		Dief("Failed to apply overlay to spec file %q: %v", specFile, err)
 	}
+	ys := docs[index]
 
-	err = yaml.NewEncoder(os.Stdout).Encode(ys)
+	result, err := o.ApplyToContext(context.Background(), ys, &overlay.ApplyOptions{
+		Strict:             applyStrict,
+		DanglingRefs:       policy,
+		ValidateSpec:       applyValidateSpec,
+		SourceMap:          applySourceMap != "",
+		ProvenanceComments: applyProvenance,
+		MinimalDiff:        applyMinimalDiff,
+		Vars:               vars,
+		Interpolate:        applyInterpolate,
+	})
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
 	if err != nil {
-		Dief("Failed to encode spec file %q: %v", specFile, err)
+		Dief("Failed to apply overlay to spec file %q: %v", specFile, err)
+	}
+
+	for _, skipped := range result.Skipped {
+		fmt.Fprintln(os.Stderr, skipped.String())
+	}
+
+	for _, ref := range result.DanglingRefs {
+		fmt.Fprintln(os.Stderr, ref.String())
+	}
+
+	invalid := false
+	for _, violation := range result.SpecViolations {
+		fmt.Fprintln(os.Stderr, violation.String())
+		if !violation.Preexisting {
+			invalid = true
+		}
+	}
+	if invalid {
+		Dief("Applying the overlay to spec file %q produced an invalid OpenAPI document", specFile)
+	}
+
+	if applyPrune {
+		pruned, err := overlay.Prune(ys, &overlay.PruneOptions{Keep: applyPruneKeep})
+		if err != nil {
+			Dief("Failed to prune spec file %q: %v", specFile, err)
+		}
+		if pruned != nil {
+			err = pruned.ApplyTo(ys)
+			if err != nil {
+				Dief("Failed to prune spec file %q: %v", specFile, err)
+			}
+		}
+	}
+
+	if applySourceMap != "" {
+		result.SourceMap.Overlay = overlayFile
+		data, err := json.MarshalIndent(result.SourceMap, "", "  ")
+		if err != nil {
+			Dief("Failed to encode source map: %v", err)
+		}
+		err = os.WriteFile(applySourceMap, append(data, '\n'), 0644)
+		if err != nil {
+			Dief("Failed to write source map %q: %v", applySourceMap, err)
+		}
+	}
+
+	if applyWriteBack || applyOutDir != "" {
+		var written []string
+		if applyOutDir != "" {
+			written, err = bundle.WriteTo(overlay.DirFS(applyOutDir))
+			for i, name := range written {
+				written[i] = filepath.Join(applyOutDir, filepath.FromSlash(name))
+			}
+		} else {
+			written, err = bundle.WriteBack()
+		}
+		for _, path := range written {
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+		}
+		if err != nil {
+			Dief("Failed to write changes back to spec file %q: %v", specFile, err)
+		}
+		return
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	for i, ys := range docs {
+		if applyMinimalDiff {
+			err = textDocs[i].Encode(os.Stdout)
+		} else {
+			err = enc.Encode(ys)
+		}
+		if err != nil {
+			Dief("Failed to encode spec file %q: %v", specFile, err)
+		}
 	}
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand runs the command line with the given arguments and returns what
+// it wrote to stdout.
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	require.NoError(t, err)
+	defer out.Close()
+
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	rootCmd.SetArgs(args)
+	require.NoError(t, rootCmd.Execute())
+
+	data, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestApply_WhenWithoutVars(t *testing.T) {
+	dir := t.TempDir()
+	overlayFile := filepath.Join(dir, "overlay.yaml")
+	require.NoError(t, os.WriteFile(overlayFile, []byte(`overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Environments
+  version: 0.0.0
+actions:
+  - target: $.info
+    x-when:
+      env: prod
+    update:
+      title: Drinks
+  - target: $.info
+    update:
+      version: 1.0.0
+`), 0644))
+	specFile := filepath.Join(dir, "openapi.yaml")
+	require.NoError(t, os.WriteFile(specFile, []byte("info: {title: Bar, version: 0.0.0}\n"), 0644))
+
+	// the conditional action is skipped, as it is by the library
+	assert.Equal(t, "info: {title: Bar, version: 1.0.0}\n", runCommand(t, "apply", overlayFile, specFile))
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
 	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
@@ -15,9 +16,19 @@ var (
 		Args:  cobra.ExactArgs(2),
 		Run:   RunCompare,
 	}
+
+	compareFormat string
 )
 
+func init() {
+	compareCmd.Flags().StringVar(&compareFormat, "format", "overlay", "output format: overlay, json-patch (RFC 6902) or merge-patch (RFC 7396)")
+}
+
 func RunCompare(cmd *cobra.Command, args []string) {
+	if compareFormat != "overlay" && compareFormat != "json-patch" && compareFormat != "merge-patch" {
+		Dief("Unknown format %q: expected overlay, json-patch or merge-patch", compareFormat)
+	}
+
 	y1, err := loader.LoadSpecification(args[0])
 	if err != nil {
 		Dief("Failed to load %q: %v", args[0], err)
@@ -28,6 +39,26 @@ func RunCompare(cmd *cobra.Command, args []string) {
 		Dief("Failed to load %q: %v", args[1], err)
 	}
 
+	var patch any
+	switch compareFormat {
+	case "json-patch":
+		patch, err = overlay.CompareJSONPatch(y1, *y2)
+	case "merge-patch":
+		patch, err = overlay.CompareMergePatch(y1, *y2)
+	}
+	if err != nil {
+		Dief("Failed to compare spec files %q and %q: %v", args[0], args[1], err)
+	}
+	if patch != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(patch)
+		if err != nil {
+			Dief("Failed to encode patch: %v", err)
+		}
+		return
+	}
+
 	title := fmt.Sprintf("Overlay %s => %s", args[0], args[1])
 
 	o, err := overlay.Compare(title, y1, *y2)
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+var (
+	importCmd = &cobra.Command{
+		Use:   "import <patch> [ <spec> ]",
+		Short: "Given a JSON Patch (RFC 6902) or JSON Merge Patch (RFC 7396), it will output the equivalent overlay. The spec, if given, is used to resolve the patch's pointers.",
+		Args:  cobra.RangeArgs(1, 2),
+		Run:   RunImport,
+	}
+)
+
+func RunImport(cmd *cobra.Command, args []string) {
+	patchFile := args[0]
+
+	node, err := loader.LoadSpecification(patchFile)
+	if err != nil {
+		Dief("Failed to load patch %q: %v", patchFile, err)
+	}
+
+	if len(node.Content) > 0 && node.Content[0].Style&yaml.FlowStyle != 0 {
+		// the patch is JSON, whose styling would look out of place in YAML
+		plainStyle(node)
+	}
+
+	opts := &overlay.ImportOptions{Title: fmt.Sprintf("Overlay imported from %s", patchFile)}
+	if len(args) > 1 {
+		opts.Spec, err = loader.LoadSpecification(args[1])
+		if err != nil {
+			Die(err)
+		}
+	}
+
+	var o *overlay.Overlay
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+		var patch overlay.JSONPatch
+		err = node.Decode(&patch)
+		if err != nil {
+			Dief("Failed to parse JSON Patch %q: %v", patchFile, err)
+		}
+		var warnings []string
+		o, warnings, err = overlay.FromJSONPatch(patch, opts)
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+	} else {
+		o, err = overlay.FromMergePatch(overlay.MergePatch{Node: node}, opts)
+	}
+	if err != nil {
+		Dief("Failed to import patch %q: %v", patchFile, err)
+	}
+
+	err = o.Format(os.Stdout)
+	if err != nil {
+		Dief("Failed to format overlay: %v", err)
+	}
+}
+
+func plainStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	for _, child := range node.Content {
+		plainStyle(child)
+	}
+}
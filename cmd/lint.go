@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+var (
+	lintCmd = &cobra.Command{
+		Use:         "lint <overlay> [ <spec> ]",
+		Short:       "Given an overlay, it will check it against the lint rules. If a spec is given, or the overlay extends a local file, it will also report actions that conflict with or shadow each other when applied to it.",
+		Args:        cobra.RangeArgs(1, 2),
+		Run:         RunLint,
+		Annotations: map[string]string{stdinFlags: "config"},
+	}
+
+	lintConfigFile string
+	lintFormat     string
+)
+
+func init() {
+	lintCmd.Flags().StringVar(&lintConfigFile, "config", "", "lint configuration file toggling rules and setting their severities")
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "output format: text or json")
+}
+
+func RunLint(cmd *cobra.Command, args []string) {
+	overlayFile := args[0]
+
+	if lintFormat != "text" && lintFormat != "json" {
+		Dief("Unknown output format %q: expected text or json", lintFormat)
+	}
+
+	o, err := loader.LoadOverlay(overlayFile)
+	if err != nil {
+		Die(err)
+	}
+
+	var cfg *overlay.LintConfig
+	if lintConfigFile != "" {
+		cfg, err = loader.LoadLintConfig(lintConfigFile)
+		if err != nil {
+			Die(err)
+		}
+	}
+
+	var ys *yaml.Node
+	if len(args) > 1 {
+		ys, err = loader.LoadSpecification(args[1])
+	} else if _, extendsErr := loader.GetOverlayExtendsPath(o); extendsErr == nil {
+		ys, err = loader.LoadExtendsSpecification(o)
+	}
+	if err != nil {
+		Die(err)
+	}
+
+	findings, err := o.Lint(cfg, ys)
+	if err != nil {
+		Dief("Failed to lint overlay %q: %v", overlayFile, err)
+	}
+
+	errorCount := 0
+	for _, finding := range findings {
+		if finding.Severity == overlay.SeverityError {
+			errorCount++
+		}
+	}
+
+	if lintFormat == "json" {
+		if findings == nil {
+			findings = []overlay.LintFinding{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(findings)
+		if err != nil {
+			Dief("Failed to encode lint findings: %v", err)
+		}
+	} else {
+		for _, finding := range findings {
+			fmt.Println(finding.String())
+		}
+		if len(findings) == 0 {
+			fmt.Printf("Overlay file %q has no lint findings.\n", overlayFile)
+		}
+	}
+
+	if errorCount > 0 {
+		Dief("Overlay file %q has %d lint errors.", overlayFile, errorCount)
+	}
+}
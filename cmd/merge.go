@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+var (
+	mergeCmd = &cobra.Command{
+		Use:   "merge <overlay>...",
+		Short: "Given several overlays, it will output a single overlay containing all of their actions in order",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   RunMerge,
+	}
+
+	mergeOutput string
+)
+
+func init() {
+	mergeCmd.Flags().StringVarP(&mergeOutput, "out", "o", "", "file to write the merged overlay to, or - for stdout (the default)")
+}
+
+func RunMerge(cmd *cobra.Command, args []string) {
+	sources := make([]overlay.MergeSource, len(args))
+	for i, overlayFile := range args {
+		o, err := loader.LoadOverlay(overlayFile)
+		if err != nil {
+			Die(err)
+		}
+		sources[i] = overlay.MergeSource{Name: overlayFile, Overlay: o}
+	}
+
+	merged, err := overlay.Merge(sources...)
+	if err != nil {
+		Dief("Failed to merge overlays: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if mergeOutput != "" && mergeOutput != "-" {
+		f, err := os.Create(mergeOutput)
+		if err != nil {
+			Dief("Failed to create output file %q: %v", mergeOutput, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = merged.Format(w)
+	if err != nil {
+		Dief("Failed to format overlay: %v", err)
+	}
+}
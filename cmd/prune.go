@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	pruneCmd = &cobra.Command{
+		Use:   "prune <spec>",
+		Short: "Given a spec, it will output an overlay removing the components that nothing outside the components section refers to.",
+		Args:  cobra.ExactArgs(1),
+		Run:   RunPrune,
+	}
+
+	pruneKeep []string
+)
+
+func init() {
+	pruneCmd.Flags().StringSliceVar(&pruneKeep, "keep", nil, "components to keep even if unreferenced, as <type>/<name> patterns such as schemas/Error or securitySchemes/*")
+}
+
+func RunPrune(cmd *cobra.Command, args []string) {
+	specFile := args[0]
+
+	ys, err := loader.LoadSpecification(specFile)
+	if err != nil {
+		Die(err)
+	}
+
+	pruned, err := overlay.Prune(ys, &overlay.PruneOptions{Keep: pruneKeep})
+	if err != nil {
+		Dief("Failed to prune spec file %q: %v", specFile, err)
+	}
+	if pruned == nil {
+		fmt.Fprintf(os.Stderr, "Nothing to prune in spec file %q\n", specFile)
+		return
+	}
+
+	err = pruned.Format(os.Stdout)
+	if err != nil {
+		Dief("Failed to format overlay: %v", err)
+	}
+}
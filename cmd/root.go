@@ -1,11 +1,27 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/spf13/cobra"
+	"slices"
+	"strings"
+)
 
 var (
 	rootCmd = &cobra.Command{
 		Use:   "openapi-overlay",
 		Short: "Work with OpenAPI Overlays",
+		Long: "Work with OpenAPI Overlays.\n\n" +
+			"Any overlay or spec argument can be - to read it from standard input. " +
+			"YAML and JSON are both accepted.",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			paths := slices.Clone(args)
+			for _, name := range strings.Split(cmd.Annotations[stdinFlags], ",") {
+				if value, err := cmd.Flags().GetString(name); err == nil {
+					paths = append(paths, value)
+				}
+			}
+			checkStdin(paths...)
+		},
 	}
 )
 
@@ -13,6 +29,12 @@ func init() {
 	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(compareCmd)
 	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(squashCmd)
+	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(splitCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(importCmd)
 }
 
 func Execute() {
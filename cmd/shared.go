@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
 	"os"
 )
 
@@ -14,3 +15,21 @@ func Die(err error) {
 	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	os.Exit(1)
 }
+
+// stdinFlags is the command annotation listing, separated by commas, the flags
+// whose values are paths that may also be "-".
+const stdinFlags = "stdin-flags"
+
+// checkStdin fails if more than one of the paths is "-", as standard input can
+// only be read once.
+func checkStdin(paths ...string) {
+	count := 0
+	for _, path := range paths {
+		if path == loader.Stdin {
+			count++
+		}
+	}
+	if count > 1 {
+		Dief("Only one file can be read from standard input, but %q was given %d times", loader.Stdin, count)
+	}
+}
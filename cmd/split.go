@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+var (
+	splitCmd = &cobra.Command{
+		Use:   "split <overlay> [ <spec> ]",
+		Short: "Given an overlay, it will split its actions into several overlay files. Grouping by tag or jsonpath requires the spec, which will be loaded via extends if omitted (only from local file system).",
+		Args:  cobra.RangeArgs(1, 2),
+		Run:   RunSplit,
+	}
+
+	splitBy        string
+	splitGroupPath string
+	splitOutDir    string
+)
+
+func init() {
+	splitCmd.Flags().StringVar(&splitBy, "by", "path", "how to group actions: path, tag or jsonpath")
+	splitCmd.Flags().StringVar(&splitGroupPath, "group-path", "", "jsonpath selecting the nodes to group actions by when using --by=jsonpath")
+	splitCmd.Flags().StringVarP(&splitOutDir, "out-dir", "d", ".", "directory to write the split overlays to")
+}
+
+func RunSplit(cmd *cobra.Command, args []string) {
+	overlayFile := args[0]
+
+	o, err := loader.LoadOverlay(overlayFile)
+	if err != nil {
+		Die(err)
+	}
+
+	var groupBy overlay.SplitFunc
+	switch splitBy {
+	case "path":
+		groupBy = overlay.SplitByPathPrefix()
+	case "tag", "jsonpath":
+		var specFile string
+		if len(args) > 1 {
+			specFile = args[1]
+		}
+		ys, _, err := loader.LoadEitherSpecification(specFile, o)
+		if err != nil {
+			Die(err)
+		}
+
+		if splitBy == "tag" {
+			groupBy = overlay.SplitByTag(ys)
+		} else {
+			if splitGroupPath == "" {
+				Dief("--group-path is required when splitting by jsonpath")
+			}
+			groupBy = overlay.SplitByJSONPath(ys, splitGroupPath)
+		}
+	default:
+		Dief("Unknown split grouping %q: expected path, tag or jsonpath", splitBy)
+	}
+
+	groups, err := o.Split(groupBy)
+	if err != nil {
+		Dief("Failed to split overlay %q: %v", overlayFile, err)
+	}
+
+	err = os.MkdirAll(splitOutDir, 0755)
+	if err != nil {
+		Dief("Failed to create output directory %q: %v", splitOutDir, err)
+	}
+
+	fileNames := overlay.SplitFileNames(groups)
+	for i, group := range groups {
+		formatted, err := group.Overlay.ToString()
+		if err != nil {
+			Dief("Failed to format overlay for group %q: %v", group.Name, err)
+		}
+
+		outFile := filepath.Join(splitOutDir, fileNames[i]+".yaml")
+		err = os.WriteFile(outFile, []byte(formatted), 0644)
+		if err != nil {
+			Dief("Failed to write overlay for group %q: %v", group.Name, err)
+		}
+
+		fmt.Printf("Wrote %d actions to %q.\n", len(group.Overlay.Actions), outFile)
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	squashCmd = &cobra.Command{
+		Use:   "squash <overlay> [ <spec> ]",
+		Short: "Given an overlay, it will output the minimal equivalent overlay for the spec. If omitted, spec will be loaded via extends (only from local file system).",
+		Args:  cobra.RangeArgs(1, 2),
+		Run:   RunSquash,
+	}
+)
+
+func RunSquash(cmd *cobra.Command, args []string) {
+	overlayFile := args[0]
+
+	o, err := loader.LoadOverlay(overlayFile)
+	if err != nil {
+		Die(err)
+	}
+
+	var specFile string
+	if len(args) > 1 {
+		specFile = args[1]
+	}
+	ys, specFile, err := loader.LoadEitherSpecification(specFile, o)
+	if err != nil {
+		Die(err)
+	}
+
+	squashed, err := o.Squash(ys)
+	if err != nil {
+		Dief("Failed to squash overlay against spec file %q: %v", specFile, err)
+	}
+
+	err = squashed.Format(os.Stdout)
+	if err != nil {
+		Dief("Failed to format overlay: %v", err)
+	}
+}
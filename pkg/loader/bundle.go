@@ -0,0 +1,590 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bundle is a specification split across several files through relative
+// $refs, loaded as a single document so that overlays can target all of it.
+//
+// Each $ref to another file, or to another part of a file other than the root
+// one, is replaced by what it refers to. Refs to URLs are left as they are.
+// Refs to the root file become local refs, such as #/components/schemas/Error.
+// Refs that would recurse into themselves point at the component of the root
+// file that refers to the same thing, or else at a copy of it added to
+// components.schemas.
+type Bundle struct {
+	// Root is the bundled document.
+	Root *yaml.Node
+
+	rootPath string
+	files    map[string]*bundleFile
+	order    []string
+
+	// refs are the refs that were inlined, in the order they were found, so
+	// that nested refs come after the refs they are nested in.
+	refs []*bundledRef
+
+	// targets are the nodes each ref refers to, as they are in their file,
+	// and pristine a copy of each as it was loaded.
+	targets  map[refKey]*yaml.Node
+	pristine map[refKey]*yaml.Node
+
+	// placed are the nodes of files that are part of the bundled document.
+	placed map[*yaml.Node]struct{}
+
+	// components are the JSON pointers of the components of the root file
+	// that refer to each target, for recursive refs to point at.
+	components map[refKey]string
+
+	// rewritten are the refs whose text was changed to point into the bundled
+	// document, and added the collections added to the root file to hold
+	// copies of recursive targets.
+	rewritten []rewrittenRef
+	added     []addedNode
+
+	unbundled bool
+}
+
+type bundleFile struct {
+	doc    *overlay.Document
+	source []byte
+
+	// pristine is a copy of the file's document as it was loaded.
+	pristine *yaml.Node
+}
+
+// refKey identifies what a ref refers to: a file, and a JSON pointer into it.
+type refKey struct {
+	path    string
+	pointer string
+}
+
+func (k refKey) String() string {
+	return k.path + "#" + k.pointer
+}
+
+type bundledRef struct {
+	key refKey
+
+	// holder is the mapping or sequence holding the ref, and ref the original
+	// $ref mapping.
+	holder *yaml.Node
+	ref    *yaml.Node
+
+	// inlined is what replaced the ref. It is the node in the target's file,
+	// unless that is already part of the bundled document, in which case it is
+	// a copy. A copy of a recursive target added to the root file's
+	// components has no ref to put back, so is removed from holder instead.
+	inlined *yaml.Node
+	copied  bool
+}
+
+type rewrittenRef struct {
+	// value is the scalar of the $ref, and original its text as loaded.
+	value    *yaml.Node
+	original string
+	rewrite  string
+}
+
+type addedNode struct {
+	holder *yaml.Node
+	value  *yaml.Node
+}
+
+// LoadBundle will load the YAML or JSON specification at the given path,
+// along with every file that its relative $refs point to, into one document.
+// Changes to the bundled document can be written back to the files they came
+// from with WriteBack.
+func LoadBundle(path string) (*Bundle, error) {
+	if path == Stdin {
+		return nil, fmt.Errorf("cannot bundle a specification read from standard input")
+	}
+	rootPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for %q: %w", path, err)
+	}
+
+	b := &Bundle{
+		rootPath: rootPath,
+		files:    map[string]*bundleFile{},
+		targets:  map[refKey]*yaml.Node{},
+		pristine: map[refKey]*yaml.Node{},
+		placed:   map[*yaml.Node]struct{}{},
+
+		components: map[refKey]string{},
+	}
+	root, err := b.file(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	b.Root = root.doc.Root
+	b.place(b.Root)
+	b.findComponents()
+
+	if err := b.inline(b.Root, rootPath, map[refKey]bool{}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Bundle) file(path string) (*bundleFile, error) {
+	if f, ok := b.files[path]; ok {
+		return f, nil
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schema from path %q: %w", path, err)
+	}
+	doc, err := overlay.ParseDocument(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema at path %q: %w", path, err)
+	}
+	f := &bundleFile{doc: doc, source: source, pristine: cloneNode(doc.Root)}
+	b.files[path] = f
+	b.order = append(b.order, path)
+	return f, nil
+}
+
+// isPlaced reports whether the node, or any of its descendants, is already
+// part of the bundled document.
+func (b *Bundle) isPlaced(node *yaml.Node) bool {
+	if _, ok := b.placed[node]; ok {
+		return true
+	}
+	return slices.ContainsFunc(node.Content, b.isPlaced)
+}
+
+func (b *Bundle) place(node *yaml.Node) {
+	b.placed[node] = struct{}{}
+	for _, child := range node.Content {
+		b.place(child)
+	}
+}
+
+// findComponents records the components of the root file that are refs to
+// other files.
+func (b *Bundle) findComponents() {
+	components := child(b.rootMapping(), "components")
+	if components == nil || components.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(components.Content); i += 2 {
+		kind, named := components.Content[i].Value, components.Content[i+1]
+		if named.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(named.Content); j += 2 {
+			ref, ok := refValue(named.Content[j+1])
+			if !ok {
+				continue
+			}
+			key, ok := b.resolve(b.rootPath, ref)
+			if _, found := b.components[key]; ok && !found && key.path != b.rootPath {
+				b.components[key] = "/components/" + escapeToken(kind) + "/" + escapeToken(named.Content[j].Value)
+			}
+		}
+	}
+}
+
+func (b *Bundle) rootMapping() *yaml.Node {
+	if b.Root.Kind == yaml.DocumentNode && len(b.Root.Content) > 0 {
+		return b.Root.Content[0]
+	}
+	return b.Root
+}
+
+// inline replaces the refs under node, which is from the file at the given
+// path, with what they refer to. The stack holds the refs being inlined.
+func (b *Bundle) inline(node *yaml.Node, path string, stack map[refKey]bool) error {
+	for i, child := range node.Content {
+		ref, ok := refValue(child)
+		if !ok {
+			if err := b.inline(child, path, stack); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, ok := b.resolve(path, ref)
+		if !ok {
+			continue
+		}
+		if key.path == b.rootPath {
+			b.rewrite(child, "#"+key.pointer)
+			continue
+		}
+		target, err := b.target(key)
+		if err != nil {
+			return fmt.Errorf("failed to resolve $ref %q in %q: %w", ref, path, err)
+		}
+		if stack[key] {
+			pointer, err := b.hoist(key)
+			if err != nil {
+				return err
+			}
+			b.rewrite(child, "#"+pointer)
+			continue
+		}
+
+		inlined, copied := target, false
+		if b.isPlaced(target) {
+			inlined, copied = cloneNode(b.pristine[key]), true
+		}
+		b.place(inlined)
+		node.Content[i] = inlined
+		b.refs = append(b.refs, &bundledRef{key: key, holder: node, ref: child, inlined: inlined, copied: copied})
+
+		stack[key] = true
+		err = b.inline(inlined, key.path, stack)
+		delete(stack, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite changes the text of the $ref mapping, if need be, to be put back
+// when the bundle is written back.
+func (b *Bundle) rewrite(ref *yaml.Node, rewrite string) {
+	value := ref.Content[1]
+	if value.Value == rewrite {
+		return
+	}
+	b.rewritten = append(b.rewritten, rewrittenRef{value: value, original: value.Value, rewrite: rewrite})
+	value.Value = rewrite
+}
+
+// hoist returns the JSON pointer of a component of the root file that a
+// recursive ref to the key can point at, adding a copy of the target to
+// components.schemas if there is none.
+func (b *Bundle) hoist(key refKey) (string, error) {
+	if pointer, ok := b.components[key]; ok {
+		return pointer, nil
+	}
+
+	components := b.addMapping(b.rootMapping(), "components")
+	schemas := b.addMapping(components, "schemas")
+	name := strings.TrimSuffix(filepath.Base(key.path), filepath.Ext(key.path))
+	if key.pointer != "" {
+		name = key.pointer[strings.LastIndex(key.pointer, "/")+1:]
+		name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+	}
+	for base, n := name, 2; child(schemas, name) != nil; n++ {
+		name = base + strconv.Itoa(n)
+	}
+	pointer := "/components/schemas/" + escapeToken(name)
+	b.components[key] = pointer
+
+	copied := cloneNode(b.pristine[key])
+	b.place(copied)
+	schemas.Content = append(schemas.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, copied)
+	b.refs = append(b.refs, &bundledRef{key: key, holder: schemas, inlined: copied, copied: true})
+	return pointer, b.inline(copied, key.path, map[refKey]bool{key: true})
+}
+
+// addMapping returns the mapping under the given key of the root file's
+// mapping, adding it if there is none.
+func (b *Bundle) addMapping(mapping *yaml.Node, key string) *yaml.Node {
+	if value := child(mapping, key); value != nil {
+		return value
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	b.added = append(b.added, addedNode{holder: mapping, value: value})
+	return value
+}
+
+func escapeToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// refValue returns the value of a mapping that is nothing but a $ref.
+func refValue(node *yaml.Node) (string, bool) {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 || node.Content[0].Value != "$ref" || node.Content[1].Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return node.Content[1].Value, true
+}
+
+// resolve works out what a ref in the file at the given path refers to. It
+// reports false for refs to URLs, which are not bundled.
+func (b *Bundle) resolve(path, ref string) (refKey, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return refKey{}, false
+	}
+
+	target := path
+	if u.Path != "" {
+		target = filepath.Join(filepath.Dir(path), filepath.FromSlash(u.Path))
+	}
+	return refKey{path: target, pointer: u.Fragment}, true
+}
+
+// target finds the node a ref refers to, loading its file if need be.
+func (b *Bundle) target(key refKey) (*yaml.Node, error) {
+	if target, ok := b.targets[key]; ok {
+		return target, nil
+	}
+
+	f, err := b.file(key.path)
+	if err != nil {
+		return nil, err
+	}
+	node, err := resolvePointer(f.doc.Root, key.pointer)
+	if err != nil {
+		return nil, err
+	}
+	pristine, err := resolvePointer(f.pristine, key.pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	b.targets[key] = node
+	b.pristine[key] = pristine
+	return node, nil
+}
+
+func resolvePointer(root *yaml.Node, pointer string) (*yaml.Node, error) {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if pointer == "" {
+		return node, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("only JSON pointer fragments are supported")
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if node = child(node, token); node == nil {
+			return nil, fmt.Errorf("%q not found", pointer)
+		}
+	}
+	return node, nil
+}
+
+func child(node *yaml.Node, token string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == token {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// WriteBack puts every inlined $ref back in place, carrying any changes made
+// to what it referred to over to the file it came from, and writes each file
+// that changed back to where it was loaded from. It returns the paths of the
+// files written. The bundle cannot be used afterwards.
+//
+// Where a ref was inlined more than once, only one of the copies may change,
+// as they all have to be written back to the same place.
+func (b *Bundle) WriteBack() ([]string, error) {
+	if err := b.unbundle(); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, path := range b.order {
+		f := b.files[path]
+		data, err := b.encode(path)
+		if err != nil {
+			return written, err
+		}
+		if bytes.Equal(data, f.source) {
+			continue
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write %q: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// WriteTo is like WriteBack, but writes every file of the specification to
+// out instead, at the same path relative to the root file's directory, so
+// that the result has the same layout as the original. It returns the names
+// of the files written. Files outside the root file's directory cannot be
+// written this way.
+func (b *Bundle) WriteTo(out overlay.WriteFS) ([]string, error) {
+	dir := filepath.Dir(b.rootPath)
+	names := make([]string, len(b.order))
+	for i, path := range b.order {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%q is outside the directory of %q", path, b.rootPath)
+		}
+		names[i] = filepath.ToSlash(rel)
+	}
+	if err := b.unbundle(); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for i, path := range b.order {
+		data, err := b.encode(path)
+		if err != nil {
+			return written, err
+		}
+		if err := out.WriteFile(names[i], data, 0644); err != nil {
+			return written, fmt.Errorf("failed to write %q: %w", names[i], err)
+		}
+		written = append(written, names[i])
+	}
+	return written, nil
+}
+
+// unbundle puts every inlined $ref back in place, carrying any changes made to
+// copies of what it referred to over to the original.
+func (b *Bundle) unbundle() error {
+	if b.unbundled {
+		return nil
+	}
+	b.unbundled = true
+
+	for _, rewritten := range b.rewritten {
+		if rewritten.value.Value == rewritten.rewrite {
+			rewritten.value.Value = rewritten.original
+		}
+	}
+
+	changed := map[refKey]*yaml.Node{}
+	for i := len(b.refs) - 1; i >= 0; i-- {
+		ref := b.refs[i]
+		index := slices.Index(ref.holder.Content, ref.inlined)
+		if index < 0 {
+			// the overlay removed or replaced the ref
+			continue
+		}
+		if ref.ref != nil {
+			ref.holder.Content[index] = ref.ref
+		} else {
+			ref.holder.Content = slices.Delete(ref.holder.Content, index-1, index+1)
+		}
+		if !ref.copied || equalNodes(ref.inlined, b.pristine[ref.key]) {
+			continue
+		}
+		if other, ok := changed[ref.key]; ok && !equalNodes(other, ref.inlined) {
+			return fmt.Errorf("$ref %q was changed differently in different places", ref.key)
+		}
+		changed[ref.key] = ref.inlined
+	}
+	for i := len(b.added) - 1; i >= 0; i-- {
+		added := b.added[i]
+		if index := slices.Index(added.holder.Content, added.value); index > 0 && len(added.value.Content) == 0 {
+			added.holder.Content = slices.Delete(added.holder.Content, index-1, index+1)
+		}
+	}
+
+	keys := make([]refKey, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, key := range keys {
+		target, copied := b.targets[key], changed[key]
+		if !equalNodes(target, b.pristine[key]) && !equalNodes(target, copied) {
+			return fmt.Errorf("$ref %q was changed differently in different places", key)
+		}
+		syncNode(target, copied)
+	}
+	return nil
+}
+
+func (b *Bundle) encode(path string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.files[path].doc.Encode(&buf); err != nil {
+		return nil, fmt.Errorf("failed to encode %q: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// syncNode makes dst the same as src. The nodes of dst that src has as well are
+// kept, so that their text is kept when the file is encoded.
+func syncNode(dst, src *yaml.Node) {
+	if equalNodes(dst, src) {
+		return
+	}
+	if dst.Kind != src.Kind || (dst.Kind != yaml.MappingNode && dst.Kind != yaml.SequenceNode) {
+		*dst = *src
+		return
+	}
+
+	content := make([]*yaml.Node, 0, len(src.Content))
+	switch src.Kind {
+	case yaml.MappingNode:
+		used := map[int]bool{}
+	NextKey:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			for j := 0; j+1 < len(dst.Content); j += 2 {
+				if !used[j] && dst.Content[j].Value == src.Content[i].Value {
+					used[j] = true
+					syncNode(dst.Content[j], src.Content[i])
+					syncNode(dst.Content[j+1], src.Content[i+1])
+					content = append(content, dst.Content[j], dst.Content[j+1])
+					continue NextKey
+				}
+			}
+			content = append(content, src.Content[i], src.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, item := range src.Content {
+			if i < len(dst.Content) {
+				syncNode(dst.Content[i], item)
+				item = dst.Content[i]
+			}
+			content = append(content, item)
+		}
+	}
+
+	synced := *src
+	synced.Content = content
+	*dst = synced
+}
+
+func cloneNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = cloneNode(child)
+	}
+	return &copied
+}
+
+// equalNodes reports whether the nodes have the same content, style and
+// comments.
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Tag != b.Tag || a.Value != b.Value || a.Style != b.Style || a.Anchor != b.Anchor ||
+		a.HeadComment != b.HeadComment || a.LineComment != b.LineComment || a.FootComment != b.FootComment ||
+		len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
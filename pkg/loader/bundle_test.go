@@ -0,0 +1,144 @@
+package loader_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// copyDir copies the files of a test case so they can be written back to.
+func copyDir(t *testing.T, dir string) string {
+	t.Helper()
+
+	out := t.TempDir()
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		require.NoError(t, os.MkdirAll(filepath.Join(out, filepath.Dir(rel)), 0755))
+		return os.WriteFile(filepath.Join(out, rel), data, 0644)
+	})
+	require.NoError(t, err)
+	return out
+}
+
+func applyToBundle(t *testing.T, b *loader.Bundle, actions string) {
+	t.Helper()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte("overlay: 1.0.0\nx-speakeasy-jsonpath: rfc9535\ninfo: {title: Test, version: 1.0.0}\n"+actions), &o))
+	require.NoError(t, o.ApplyTo(b.Root))
+}
+
+func TestLoadBundle(t *testing.T) {
+	t.Parallel()
+
+	b, err := loader.LoadBundle("testdata/bundle/openapi.yaml")
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, b.Root.Decode(&doc))
+	paths := doc["paths"].(map[string]any)
+	get := paths["/drinks"].(map[string]any)["get"].(map[string]any)
+	assert.Equal(t, "List drinks", get["summary"], "refs to other files should be inlined")
+
+	drink := doc["components"].(map[string]any)["schemas"].(map[string]any)["Drink"].(map[string]any)
+	properties := drink["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"}, properties["child"], "refs within other files should be inlined")
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/Drink"}, properties["self"], "recursive refs should point at the component")
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/Drink"}, doc["components"].(map[string]any)["schemas"].(map[string]any)["Local"], "refs within the root file should be left alone")
+
+	responses := get["responses"].(map[string]any)
+	assert.Equal(t, map[string]any{"$ref": "#/components/responses/Error"}, responses["default"], "refs to the root file should become local refs")
+	items := responses["200"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)["items"].(map[string]any)
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/Drink"}, items["properties"].(map[string]any)["self"])
+
+	// section.yaml is only referred to from a path, so it is hoisted into the
+	// components for its recursive ref to point at
+	section := doc["components"].(map[string]any)["schemas"].(map[string]any)["section"].(map[string]any)
+	sectionRef := map[string]any{"$ref": "#/components/schemas/section"}
+	assert.Equal(t, sectionRef, section["properties"].(map[string]any)["sections"].(map[string]any)["items"])
+	menu := paths["/menu"].(map[string]any)["get"].(map[string]any)["responses"].(map[string]any)["200"].(map[string]any)
+	schema := menu["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	assert.Equal(t, sectionRef, schema["properties"].(map[string]any)["sections"].(map[string]any)["items"])
+
+	_, err = loader.LoadBundle("testdata/bundle/missing.yaml")
+	assert.Error(t, err)
+}
+
+func TestBundle_WriteBack(t *testing.T) {
+	t.Parallel()
+
+	dir := copyDir(t, "testdata/bundle")
+	b, err := loader.LoadBundle(filepath.Join(dir, "openapi.yaml"))
+	require.NoError(t, err)
+	applyToBundle(t, b, `actions:
+  - target: $.paths["/drinks"].get
+    update:
+      summary: List all drinks
+  - target: $.components.schemas.Drink.properties
+    update:
+      price: {type: number}
+`)
+
+	written, err := b.WriteBack()
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "paths/drinks.yaml"), filepath.Join(dir, "schemas/drink.yaml")}, written)
+
+	root, err := os.ReadFile(filepath.Join(dir, "openapi.yaml"))
+	require.NoError(t, err)
+	expected, err := os.ReadFile("testdata/bundle/openapi.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(root), "the root file should keep its refs")
+
+	drinks, err := os.ReadFile(filepath.Join(dir, "paths/drinks.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(drinks), "  summary: List all drinks\n")
+	assert.Contains(t, string(drinks), "              $ref: ../schemas/drink.yaml\n")
+	assert.Contains(t, string(drinks), "      $ref: ../openapi.yaml#/components/responses/Error\n", "refs to the root file should be restored")
+
+	drink, err := os.ReadFile(filepath.Join(dir, "schemas/drink.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, `type: object   # a drink
+properties:
+  name: {type: string}
+  child:
+    $ref: "#/properties/name"
+  self:
+    $ref: ./drink.yaml
+  price: {type: number}
+`, string(drink), "changes to a copy of a ref should be written back, keeping the rest of the file as it was")
+}
+
+func TestBundle_WriteBackConflict(t *testing.T) {
+	t.Parallel()
+
+	dir := copyDir(t, "testdata/bundle")
+	b, err := loader.LoadBundle(filepath.Join(dir, "openapi.yaml"))
+	require.NoError(t, err)
+	applyToBundle(t, b, `actions:
+  - target: $.paths["/drinks"].get.responses["200"].content["application/json"].schema.items
+    update:
+      description: One drink.
+  - target: $.components.schemas.Drink
+    update:
+      description: A drink.
+`)
+
+	_, err = b.WriteBack()
+	assert.ErrorContains(t, err, "was changed differently in different places")
+}
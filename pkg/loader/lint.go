@@ -0,0 +1,25 @@
+package loader
+
+import (
+	"fmt"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadLintConfig will load and parse a YAML or JSON lint configuration file
+// from the given path.
+func LoadLintConfig(path string) (*overlay.LintConfig, error) {
+	rs, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lint config from path %q: %w", path, err)
+	}
+	defer rs.Close()
+
+	var cfg overlay.LintConfig
+	err = yaml.NewDecoder(rs).Decode(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lint config at path %q: %w", path, err)
+	}
+
+	return &cfg, nil
+}
@@ -3,11 +3,24 @@ package loader
 import (
 	"fmt"
 	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"io/fs"
 )
 
 // LoadOverlay is a tool for loading and parsing an overlay file from the file
 // system.
 func LoadOverlay(path string) (*overlay.Overlay, error) {
+	if path == Stdin {
+		rs, err := open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open overlay from standard input: %w", err)
+		}
+		o, err := overlay.ParseReader(rs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse overlay from standard input: %w", err)
+		}
+		return o, nil
+	}
+
 	o, err := overlay.Parse(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse overlay from path %q: %w", path, err)
@@ -15,3 +28,14 @@ func LoadOverlay(path string) (*overlay.Overlay, error) {
 
 	return o, nil
 }
+
+// LoadOverlayFS is like LoadOverlay, but loads the overlay file from the given
+// path in fsys, such as an embed.FS.
+func LoadOverlayFS(fsys fs.FS, path string) (*overlay.Overlay, error) {
+	o, err := overlay.ParseFS(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse overlay from path %q: %w", path, err)
+	}
+
+	return o, nil
+}
@@ -1,11 +1,13 @@
 package loader
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
 	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
 	"net/url"
-	"os"
 )
 
 // GetOverlayExtendsPath returns the path to file if the extends URL is a file
@@ -43,20 +45,91 @@ func LoadExtendsSpecification(o *overlay.Overlay) (*yaml.Node, error) {
 
 // LoadSpecification will load and parse a YAML or JSON file from the given path.
 func LoadSpecification(path string) (*yaml.Node, error) {
-	rs, err := os.Open(path)
+	rs, err := open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open schema from path %q: %w", path, err)
 	}
+	defer rs.Close()
 
-	var ys yaml.Node
-	err = yaml.NewDecoder(rs).Decode(&ys)
+	ys, err := LoadSpecificationReader(rs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse schema at path %q: %w", path, err)
 	}
 
+	return ys, nil
+}
+
+// LoadSpecificationFS will load and parse a YAML or JSON file from the given
+// path in fsys, such as an embed.FS.
+func LoadSpecificationFS(fsys fs.FS, path string) (*yaml.Node, error) {
+	rs, err := fsys.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schema from path %q: %w", path, err)
+	}
+	defer rs.Close()
+
+	ys, err := LoadSpecificationReader(rs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema at path %q: %w", path, err)
+	}
+
+	return ys, nil
+}
+
+// LoadSpecificationReader will parse the YAML or JSON document read from r.
+// It fails if there is more than one document; see
+// LoadSpecificationDocuments.
+func LoadSpecificationReader(r io.Reader) (*yaml.Node, error) {
+	dec := yaml.NewDecoder(r)
+	var ys yaml.Node
+	err := dec.Decode(&ys)
+	if err != nil {
+		return nil, err
+	}
+
+	var next yaml.Node
+	err = dec.Decode(&next)
+	if err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("expected a single document, but found more than one")
+	}
+
 	return &ys, nil
 }
 
+// LoadSpecificationDocuments will load and parse every document of a YAML
+// stream from the given path.
+func LoadSpecificationDocuments(path string) ([]*yaml.Node, error) {
+	rs, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schema from path %q: %w", path, err)
+	}
+	defer rs.Close()
+
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(rs)
+	for {
+		var ys yaml.Node
+		err := dec.Decode(&ys)
+		if err == io.EOF && len(docs) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schema at path %q: %w", path, err)
+		}
+		docs = append(docs, &ys)
+	}
+
+	return docs, nil
+}
+
+// LoadSpecificationBytes will parse the given YAML or JSON document.
+func LoadSpecificationBytes(data []byte) (*yaml.Node, error) {
+	return LoadSpecificationReader(bytes.NewReader(data))
+}
+
 // LoadEitherSpecification is a convenience function that will load a
 // specification from the given file path if it is non-empty. Otherwise, it will
 // attempt to load the path from the overlay's extends URL. Also returns the name
@@ -76,3 +149,79 @@ func LoadEitherSpecification(path string, o *overlay.Overlay) (*yaml.Node, strin
 
 	return y, path, err
 }
+
+// LoadDocument will load and parse a YAML or JSON file from the given path,
+// keeping its text so that it can be written back out with minimal changes.
+func LoadDocument(path string) (*overlay.Document, error) {
+	data, err := readAll(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := overlay.ParseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema at path %q: %w", path, err)
+	}
+
+	return doc, nil
+}
+
+// LoadDocuments is like LoadDocument, but loads every document of a YAML
+// stream.
+func LoadDocuments(path string) ([]*overlay.Document, error) {
+	data, err := readAll(path)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := overlay.ParseDocuments(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema at path %q: %w", path, err)
+	}
+
+	return docs, nil
+}
+
+// LoadEitherDocuments is like LoadEitherSpecification, but loads every
+// document of a YAML stream as a Document.
+func LoadEitherDocuments(path string, o *overlay.Overlay) ([]*overlay.Document, string, error) {
+	if path == "" {
+		var err error
+		path, err = GetOverlayExtendsPath(o)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	docs, err := LoadDocuments(path)
+	return docs, path, err
+}
+
+// LoadEitherSpecificationDocuments is like LoadEitherSpecification, but loads
+// every document of a YAML stream.
+func LoadEitherSpecificationDocuments(path string, o *overlay.Overlay) ([]*yaml.Node, string, error) {
+	if path == "" {
+		var err error
+		path, err = GetOverlayExtendsPath(o)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	docs, err := LoadSpecificationDocuments(path)
+	return docs, path, err
+}
+
+func readAll(path string) ([]byte, error) {
+	rs, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schema from path %q: %w", path, err)
+	}
+	defer rs.Close()
+
+	data, err := io.ReadAll(rs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema from path %q: %w", path, err)
+	}
+	return data, nil
+}
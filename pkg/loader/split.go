@@ -0,0 +1,41 @@
+package loader
+
+import (
+	"context"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+)
+
+// ApplyToFiles applies the overlay to a specification split across files
+// through relative $refs, keeping the files as they are laid out. Targets are
+// evaluated against the specification as LoadBundle bundles it, and each
+// change lands in the file the changed node came from.
+//
+// The specification is bundled in memory to do so: a target such as
+// $.paths["/drinks"].get.summary can only be matched once the refs it crosses
+// have been followed, which is what bundling does. The bundled document is
+// never written out. Its refs are put back, and each file is written with the
+// original text of everything the overlay did not change.
+//
+// If out is nil, only the files that changed are written back in place, and
+// files no action touched are left alone. Otherwise
+// every file is written to out, at the same path relative to the directory of
+// the root file. The names of the files written are returned along with the
+// result of applying the overlay.
+func ApplyToFiles(ctx context.Context, o *overlay.Overlay, path string, out overlay.WriteFS, opts *overlay.ApplyOptions) (*overlay.ApplyResult, []string, error) {
+	bundle, err := LoadBundle(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := o.ApplyToContext(ctx, bundle.Root, opts)
+	if err != nil {
+		return result, nil, err
+	}
+
+	var written []string
+	if out == nil {
+		written, err = bundle.WriteBack()
+	} else {
+		written, err = bundle.WriteTo(out)
+	}
+	return result, written, err
+}
@@ -0,0 +1,50 @@
+package loader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyToFiles(t *testing.T) {
+	t.Parallel()
+
+	o, err := overlay.ParseBytes([]byte(`overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info: {title: Test, version: 1.0.0}
+actions:
+  - target: $.paths["/drinks"].get
+    update:
+      summary: List all drinks
+`))
+	require.NoError(t, err)
+
+	out := t.TempDir()
+	_, written, err := loader.ApplyToFiles(context.Background(), o, "testdata/bundle/openapi.yaml", overlay.DirFS(out), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openapi.yaml", "paths/drinks.yaml", "schemas/drink.yaml", "paths/menu.yaml", "schemas/section.yaml"}, written)
+
+	for _, name := range []string{"openapi.yaml", "schemas/drink.yaml", "paths/menu.yaml", "schemas/section.yaml"} {
+		expected, err := os.ReadFile(filepath.Join("testdata/bundle", name))
+		require.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join(out, name))
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual), "%s should be written unchanged", name)
+	}
+
+	drinks, err := os.ReadFile(filepath.Join(out, "paths/drinks.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(drinks), "  summary: List all drinks\n")
+	assert.Contains(t, string(drinks), "              $ref: ../schemas/drink.yaml\n")
+
+	dir := copyDir(t, "testdata/bundle")
+	_, written, err = loader.ApplyToFiles(context.Background(), o, filepath.Join(dir, "openapi.yaml"), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "paths/drinks.yaml")}, written, "only changed files should be written in place")
+}
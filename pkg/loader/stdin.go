@@ -0,0 +1,34 @@
+package loader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Stdin is the path that stands for standard input, as is usual for command
+// line tools. Any of the loaders given it read from standard input instead of
+// a file. Standard input can only be read once: loading from it again fails.
+const Stdin = "-"
+
+var (
+	stdinMu   sync.Mutex
+	stdinRead *os.File
+)
+
+// open opens the file at the given path, or standard input for Stdin, unless
+// it has already been read.
+func open(path string) (io.ReadCloser, error) {
+	if path != Stdin {
+		return os.Open(path)
+	}
+
+	stdinMu.Lock()
+	defer stdinMu.Unlock()
+	if stdinRead == os.Stdin {
+		return nil, errors.New("standard input has already been read")
+	}
+	stdinRead = os.Stdin
+	return io.NopCloser(os.Stdin), nil
+}
@@ -0,0 +1,59 @@
+package loader_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setStdin replaces standard input with the given content for the rest of the
+// test, which must therefore not run in parallel.
+func setStdin(t *testing.T, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	in, err := os.Open(path)
+	require.NoError(t, err)
+
+	stdin := os.Stdin
+	os.Stdin = in
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		in.Close()
+	})
+}
+
+func TestStdin(t *testing.T) {
+	setStdin(t, "overlay: 1.0.0\ninfo: {title: From stdin, version: 1.0.0}\nactions: []\n")
+	o, err := loader.LoadOverlay(loader.Stdin)
+	require.NoError(t, err)
+	assert.Equal(t, "From stdin", o.Info.Title)
+
+	setStdin(t, `{"openapi": "3.1.0", "info": {"title": "From stdin"}}`)
+	node, err := loader.LoadSpecification(loader.Stdin)
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, node.Decode(&doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+
+	setStdin(t, "env: prod\n")
+	vars, err := loader.LoadVars(loader.Stdin)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod"}, vars)
+}
+
+func TestStdin_ReadTwice(t *testing.T) {
+	setStdin(t, "env: prod\n")
+	_, err := loader.LoadVars(loader.Stdin)
+	require.NoError(t, err)
+
+	_, err = loader.LoadSpecification(loader.Stdin)
+	assert.EqualError(t, err, `failed to open schema from path "-": standard input has already been read`)
+	_, err = loader.LoadOverlay(loader.Stdin)
+	assert.EqualError(t, err, "failed to open overlay from standard input: standard input has already been read")
+}
@@ -0,0 +1,40 @@
+package loader
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadVars will load a YAML or JSON file of variables, such as those used by
+// x-when conditions and interpolation, from the given path. The file must be a
+// mapping of names to scalar values, which are taken as they are written.
+func LoadVars(path string) (map[string]string, error) {
+	rs, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vars file from path %q: %w", path, err)
+	}
+	defer rs.Close()
+
+	var node yaml.Node
+	err = yaml.NewDecoder(rs).Decode(&node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vars file at path %q: %w", path, err)
+	}
+
+	vars := map[string]string{}
+	if len(node.Content) == 0 {
+		return vars, nil
+	}
+	mapping := node.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("vars file at path %q must be a mapping of names to values", path)
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("variable %q in vars file at path %q must be a string, number or boolean", key.Value, path)
+		}
+		vars[key.Value] = value.Value
+	}
+	return vars, nil
+}
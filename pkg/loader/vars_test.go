@@ -0,0 +1,26 @@
+package loader_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadVars(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vars.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("version: 1.10\nenv: prod\nbeta: true\n"), 0644))
+	vars, err := loader.LoadVars(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"version": "1.10", "env": "prod", "beta": "true"}, vars, "values should be kept as written")
+
+	require.NoError(t, os.WriteFile(path, []byte("servers: [a, b]\n"), 0644))
+	_, err = loader.LoadVars(path)
+	assert.ErrorContains(t, err, `variable "servers"`)
+}
@@ -1,189 +1,207 @@
 package overlay
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/config"
 	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/token"
 	"gopkg.in/yaml.v3"
-	"strings"
+	"slices"
 )
 
 // ApplyTo will take an overlay and apply its changes to the given YAML
 // document.
 func (o *Overlay) ApplyTo(root *yaml.Node) error {
-	for _, action := range o.Actions {
-		var err error
-		if action.Remove {
-			err = o.applyRemoveAction(root, action, nil)
-		} else {
-			err = o.applyUpdateAction(root, action, &[]string{})
-		}
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return o.compile().ApplyTo(root)
 }
 
-func (o *Overlay) ApplyToStrict(root *yaml.Node) (error, []string) {
-	multiError := []string{}
-	warnings := []string{}
-	hasFilterExpression := false
-	for i, action := range o.Actions {
-		tokens := token.NewTokenizer(action.Target, config.WithPropertyNameExtension()).Tokenize()
-		for _, tok := range tokens {
-			if tok.Token == token.FILTER {
-				hasFilterExpression = true
-			}
-		}
-
-		actionWarnings := []string{}
-		err := o.validateSelectorHasAtLeastOneTarget(root, action)
-		if err != nil {
-			multiError = append(multiError, err.Error())
-		}
-		if action.Remove {
-			err = o.applyRemoveAction(root, action, &actionWarnings)
-		} else {
-			err = o.applyUpdateAction(root, action, &actionWarnings)
-		}
-		for _, warning := range actionWarnings {
-			warnings = append(warnings, fmt.Sprintf("update action (%v / %v) target=%s: %s", i+1, len(o.Actions), action.Target, warning))
-		}
-	}
-
-	if hasFilterExpression && !o.UsesRFC9535() {
-		warnings = append(warnings, "overlay has a filter expression but lacks `x-speakeasy-jsonpath: rfc9535` extension. Deprecated jsonpath behaviour in use. See overlay.speakeasy.com for the implementation playground.")
-	}
-
-	if len(multiError) > 0 {
-		return fmt.Errorf("error applying overlay (strict): %v", strings.Join(multiError, ",")), warnings
-	}
-	return nil, warnings
+// ApplyToContext will apply the overlay's changes to the given YAML document
+// as configured by the options, which may be nil. See
+// CompiledOverlay.ApplyToContext for how cancellation is handled.
+func (o *Overlay) ApplyToContext(ctx context.Context, root *yaml.Node, opts *ApplyOptions) (*ApplyResult, error) {
+	return o.compile().ApplyToContext(ctx, root, opts)
 }
 
-func (o *Overlay) validateSelectorHasAtLeastOneTarget(root *yaml.Node, action Action) error {
-	if action.Target == "" {
+// applyAction applies a single action of the overlay to the given document,
+// discarding any warnings.
+func (o *Overlay) applyAction(state *applyState, action Action) error {
+	compiled := o.compileAction(action)
+	if !compiled.hasEffect() {
 		return nil
 	}
 
-	p, err := o.NewPath(action.Target, nil)
+	nodes, err := compiled.query(state.root)
 	if err != nil {
 		return err
 	}
+	return compiled.applyTo(state, nodes, &[]string{})
+}
 
-	nodes := p.Query(root)
+func (o *Overlay) ApplyToStrict(root *yaml.Node) (error, []string) {
+	return o.compile().ApplyToStrict(root)
+}
 
-	if len(nodes) == 0 {
-		return fmt.Errorf("selector %q did not match any targets", action.Target)
+// targetHasFilterExpression reports whether the target uses a filter
+// expression, whose semantics differ between the legacy and RFC 9535 JSONPath
+// implementations.
+func targetHasFilterExpression(target string) bool {
+	tokens := token.NewTokenizer(target, config.WithPropertyNameExtension()).Tokenize()
+	for _, tok := range tokens {
+		if tok.Token == token.FILTER {
+			return true
+		}
 	}
-
-	return nil
+	return false
 }
 
-func (o *Overlay) applyRemoveAction(root *yaml.Node, action Action, warnings *[]string) error {
-	if action.Target == "" {
-		return nil
+// validateSelectorHasAtLeastOneTarget checks that the action's target matched
+// something, as the policy decides. For NoMatchWarn it returns a warning
+// instead of an error.
+func validateSelectorHasAtLeastOneTarget(action Action, nodes []*yaml.Node, policy NoMatchPolicy) (string, error) {
+	if action.Target == "" || len(nodes) > 0 {
+		return "", nil
 	}
 
-	idx := newParentIndex(root)
-
-	p, err := o.NewPath(action.Target, warnings)
-	if err != nil {
-		return err
+	message := fmt.Sprintf("selector %q did not match any targets", action.Target)
+	switch policy {
+	case NoMatchWarn:
+		return message, nil
+	case NoMatchIgnore:
+		return "", nil
+	default:
+		return "", errors.New(message)
 	}
+}
 
-	nodes := p.Query(root)
-	if err != nil {
-		return err
+func applyRemoveAction(state *applyState, nodes []*yaml.Node) error {
+	return removeNodes(state.ctx, state.parents(), nodes)
+}
+
+// removeNodes removes the given nodes from their parents. Each parent's content
+// is rewritten once, however many of its children are removed, and the index is
+// kept up to date.
+func removeNodes(ctx context.Context, idx *parentIndex, nodes []*yaml.Node) error {
+	removed := map[*yaml.Node][]int{}
+	var parents []*yaml.Node
+	for i, node := range nodes {
+		if err := checkContext(ctx, i); err != nil {
+			return err
+		}
+
+		parent := idx.getParent(node)
+		if parent == nil || (parent.Kind != yaml.MappingNode && parent.Kind != yaml.SequenceNode) {
+			continue
+		}
+		p := idx.position(parent, node)
+		if p < 0 {
+			continue
+		}
+		if _, seen := removed[parent]; !seen {
+			parents = append(parents, parent)
+		}
+		if parent.Kind == yaml.MappingNode {
+			// selecting either the key or the value deletes both
+			p -= p % 2
+			removed[parent] = append(removed[parent], p, p+1)
+		} else {
+			removed[parent] = append(removed[parent], p)
+		}
 	}
 
-	for _, node := range nodes {
-		removeNode(idx, node)
+	for _, parent := range parents {
+		positions := removed[parent]
+		slices.Sort(positions)
+		idx.removeChildren(parent, slices.Compact(positions))
 	}
 
 	return nil
 }
 
-func removeNode(idx parentIndex, node *yaml.Node) {
-	parent := idx.getParent(node)
-	if parent == nil {
-		return
-	}
-
-	for i, child := range parent.Content {
-		if child == node {
-			switch parent.Kind {
-			case yaml.MappingNode:
-				if i%2 == 1 {
-					// if we select a value, we should delete the key too
-					parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
-				} else {
-					// if we select a key, we should delete the value
-					parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
-				}
-				return
-			case yaml.SequenceNode:
-				parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
-				return
-			}
+func applyUpdateAction(state *applyState, action Action, nodes []*yaml.Node, warnings *[]string) error {
+	m := merger{idx: state.idx, touched: state.touched, keepComments: state.keepComments}
+	didMakeChange := false
+	for i, node := range nodes {
+		if err := checkContext(state.ctx, i); err != nil {
+			return err
 		}
+		didMakeChange = m.mergeNode(node, &action.Update) || didMakeChange
 	}
+	if !didMakeChange {
+		*warnings = append(*warnings, "does nothing")
+	}
+	return nil
 }
 
-func (o *Overlay) applyUpdateAction(root *yaml.Node, action Action, warnings *[]string) error {
-	if action.Target == "" {
-		return nil
-	}
+// contextCheckInterval is how many matched nodes are modified between checks
+// for cancellation.
+const contextCheckInterval = 1024
 
-	if action.Update.IsZero() {
+// checkContext returns the context's error every contextCheckInterval nodes.
+func checkContext(ctx context.Context, i int) error {
+	if i%contextCheckInterval != 0 {
 		return nil
 	}
+	return ctx.Err()
+}
 
-	p, err := o.NewPath(action.Target, warnings)
-	if err != nil {
-		return err
-	}
+// merger merges update nodes into the document. The parent index, if one has
+// been built, is updated with any nodes that are added.
+type merger struct {
+	idx *parentIndex
 
-	nodes := p.Query(root)
+	// touched, if set, is called with each node a merge creates or changes.
+	// Nodes created along with a new ancestor are not reported separately.
+	touched func(node *yaml.Node)
 
-	didMakeChange := false
-	for _, node := range nodes {
-		didMakeChange = updateNode(node, &action.Update) || didMakeChange
-	}
-	if !didMakeChange {
-		*warnings = append(*warnings, "does nothing")
-	}
-
-	return nil
+	// keepComments keeps the comments of a node replaced by one of a
+	// different kind, where the new node has none of its own.
+	keepComments bool
 }
 
-func updateNode(node *yaml.Node, updateNode *yaml.Node) bool {
-	return mergeNode(node, updateNode)
+func (m merger) touch(node *yaml.Node) {
+	if m.touched != nil {
+		m.touched(node)
+	}
 }
 
-func mergeNode(node *yaml.Node, merge *yaml.Node) bool {
+func (m merger) mergeNode(node *yaml.Node, merge *yaml.Node) bool {
 	if node.Kind != merge.Kind {
-		*node = *clone(merge)
+		replacement := clone(merge)
+		if m.keepComments {
+			keepComment(&replacement.HeadComment, node.HeadComment)
+			keepComment(&replacement.LineComment, node.LineComment)
+			keepComment(&replacement.FootComment, node.FootComment)
+		}
+		*node = *replacement
+		m.idx.indexNodeRecursively(node)
+		m.touch(node)
 		return true
 	}
 	switch node.Kind {
 	default:
 		isChanged := node.Value != merge.Value
 		node.Value = merge.Value
+		if isChanged {
+			m.touch(node)
+		}
 		return isChanged
 	case yaml.MappingNode:
-		return mergeMappingNode(node, merge)
+		return m.mergeMappingNode(node, merge)
 	case yaml.SequenceNode:
-		return mergeSequenceNode(node, merge)
+		return m.mergeSequenceNode(node, merge)
+	}
+}
+
+func keepComment(comment *string, original string) {
+	if *comment == "" {
+		*comment = original
 	}
 }
 
 // mergeMappingNode will perform a shallow merge of the merge node into the main
 // node.
-func mergeMappingNode(node *yaml.Node, merge *yaml.Node) bool {
+func (m merger) mergeMappingNode(node *yaml.Node, merge *yaml.Node) bool {
 	anyChange := false
 NextKey:
 	for i := 0; i < len(merge.Content); i += 2 {
@@ -193,23 +211,62 @@ NextKey:
 		for j := 0; j < len(node.Content); j += 2 {
 			nodeKey := node.Content[j].Value
 			if nodeKey == mergeKey {
-				anyChange = mergeNode(node.Content[j+1], mergeValue) || anyChange
+				anyChange = m.mergeNode(node.Content[j+1], mergeValue) || anyChange
 				continue NextKey
 			}
 		}
 
-		node.Content = append(node.Content, merge.Content[i], clone(mergeValue))
+		value := clone(mergeValue)
+		node.Content = append(node.Content, clone(merge.Content[i]), value)
+		m.idx.indexChildren(node, len(node.Content)-2)
+		m.touch(value)
 		anyChange = true
 	}
 	return anyChange
 }
 
 // mergeSequenceNode will append the merge node's content to the original node.
-func mergeSequenceNode(node *yaml.Node, merge *yaml.Node) bool {
+func (m merger) mergeSequenceNode(node *yaml.Node, merge *yaml.Node) bool {
+	start := len(node.Content)
 	node.Content = append(node.Content, clone(merge).Content...)
+	m.idx.indexChildren(node, start)
+	for _, item := range node.Content[start:] {
+		m.touch(item)
+	}
 	return true
 }
 
+// applyState is shared by the actions of a single application of an overlay.
+type applyState struct {
+	ctx  context.Context
+	root *yaml.Node
+
+	// idx is built by the first action that needs it and kept up to date by the
+	// actions after that, rather than walking the whole document every time.
+	idx *parentIndex
+
+	// touched, if set, is called with each node an update creates or changes.
+	touched func(node *yaml.Node)
+
+	// keepComments is set by ApplyOptions.MinimalDiff.
+	keepComments bool
+
+	// vars, if set, are interpolated into updates, as asked for by
+	// ApplyOptions.Interpolate.
+	vars map[string]string
+}
+
+func newApplyState(root *yaml.Node) *applyState {
+	return &applyState{ctx: context.Background(), root: root}
+}
+
+func (s *applyState) parents() *parentIndex {
+	if s.idx == nil {
+		s.idx = newParentIndex(s.root)
+	}
+	return s.idx
+}
+
 func clone(node *yaml.Node) *yaml.Node {
 	newNode := &yaml.Node{
 		Kind:        node.Kind,
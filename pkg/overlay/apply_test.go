@@ -2,6 +2,7 @@ package overlay_test
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/speakeasy-api/jsonpath/pkg/jsonpath"
 	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
 	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
@@ -175,35 +176,45 @@ func BenchmarkApplyToStrictBySize(b *testing.B) {
 	// Approximate size contribution of one path (accounting for YAML structure)
 	pathItemSize := pathBuf.Len() - 10 // Subtract some overhead
 
-	for _, target := range targetSizes {
-		b.Run(target.name, func(b *testing.B) {
-			// Create a copy of the base spec
-			specCopy := cloneNode(&baseSpec)
-			pathsNodeCopy := findPathsNode(specCopy)
-
-			// Calculate how many paths we need to add
-			bytesNeeded := target.size - baseSize
-			pathsToAdd := 0
-			if bytesNeeded > 0 {
-				pathsToAdd = bytesNeeded / pathItemSize
-				// Add a few extra to ensure we exceed the target
-				pathsToAdd += 5
-			}
+	// buildSpec duplicates the template path until the spec reaches the target
+	// size, returning the spec and the keys of the paths that were added.
+	buildSpec := func(b *testing.B, targetSize int) (*yaml.Node, []string) {
+		// Create a copy of the base spec
+		specCopy := cloneNode(&baseSpec)
+		pathsNodeCopy := findPathsNode(specCopy)
+
+		// Calculate how many paths we need to add
+		bytesNeeded := targetSize - baseSize
+		pathsToAdd := 0
+		if bytesNeeded > 0 {
+			pathsToAdd = bytesNeeded / pathItemSize
+			// Add a few extra to ensure we exceed the target
+			pathsToAdd += 5
+		}
 
-			// Add the calculated number of path duplicates
-			for i := 0; i < pathsToAdd; i++ {
-				newPathKey := yaml.Node{Kind: yaml.ScalarNode, Value: templateKey + "-duplicate-" + strconv.Itoa(i)}
-				newPathValue := cloneNode(templatePath)
-				pathsNodeCopy.Content = append(pathsNodeCopy.Content, &newPathKey, newPathValue)
-			}
+		// Add the calculated number of path duplicates
+		addedKeys := make([]string, 0, pathsToAdd)
+		for i := 0; i < pathsToAdd; i++ {
+			newPathKey := yaml.Node{Kind: yaml.ScalarNode, Value: templateKey + "-duplicate-" + strconv.Itoa(i)}
+			newPathValue := cloneNode(templatePath)
+			pathsNodeCopy.Content = append(pathsNodeCopy.Content, &newPathKey, newPathValue)
+			addedKeys = append(addedKeys, newPathKey.Value)
+		}
 
-			// Verify final size
-			var finalBuf bytes.Buffer
-			finalEnc := yaml.NewEncoder(&finalBuf)
-			err = finalEnc.Encode(specCopy)
-			require.NoError(b, err)
-			actualSize := finalBuf.Len()
-			b.Logf("OpenAPI size: %d bytes (target: %d, paths added: %d)", actualSize, target.size, pathsToAdd)
+		// Verify final size
+		var finalBuf bytes.Buffer
+		finalEnc := yaml.NewEncoder(&finalBuf)
+		err := finalEnc.Encode(specCopy)
+		require.NoError(b, err)
+		actualSize := finalBuf.Len()
+		b.Logf("OpenAPI size: %d bytes (target: %d, paths added: %d)", actualSize, targetSize, pathsToAdd)
+
+		return specCopy, addedKeys
+	}
+
+	for _, target := range targetSizes {
+		b.Run(target.name, func(b *testing.B) {
+			specCopy, _ := buildSpec(b, target.size)
 
 			// Load overlay
 			var o overlay.Overlay
@@ -217,6 +228,34 @@ func BenchmarkApplyToStrictBySize(b *testing.B) {
 				_, _ = o.ApplyToStrict(specForTest)
 			}
 		})
+
+		b.Run(target.name+"-remove-heavy", func(b *testing.B) {
+			specCopy, addedKeys := buildSpec(b, target.size)
+
+			// Remove every added path, plus one operation of each, one action at a time
+			o := overlay.Overlay{
+				Version:         "1.0.0",
+				JSONPathVersion: "rfc9535",
+				Info:            overlay.Info{Title: "Remove heavy", Version: "0.0.0"},
+			}
+			for _, key := range addedKeys {
+				o.Actions = append(o.Actions,
+					overlay.Action{Target: fmt.Sprintf(`$["paths"][%q]["get"]`, key), Remove: true},
+					overlay.Action{Target: fmt.Sprintf(`$["paths"][%q]`, key), Remove: true},
+				)
+			}
+			b.Logf("Remove actions: %d", len(o.Actions))
+
+			// Run the benchmark, removing from a fresh copy every time
+			b.ResetTimer()
+			for b.Loop() {
+				b.StopTimer()
+				specForTest := cloneNode(specCopy)
+				b.StartTimer()
+
+				_, _ = o.ApplyToStrict(specForTest)
+			}
+		})
 	}
 }
 
@@ -306,3 +345,102 @@ func TestApplyToOld(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 0, len(result))
 }
+
+func TestApplyTo_RemoveAfterUpdate(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(`
+a:
+  keep: 1
+  drop: 2
+b: [1, 2, 3]
+`), &node)
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	err = yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Remove after update
+  version: 0.0.0
+actions:
+  - target: $.a.drop
+    remove: true
+  - target: $.a
+    update:
+      added:
+        nested: true
+        gone: true
+  - target: $.b
+    update: [4, 5]
+  - target: $.a.added.gone
+    remove: true
+  - target: $.b[?(@ > 2)]
+    remove: true
+  - target: $.a.added
+    update: [x, y]
+  - target: $.a.added[0]
+    remove: true
+`), &o)
+	require.NoError(t, err)
+
+	err = o.ApplyTo(&node)
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Equal(t, "a:\n    keep: 1\n    added: [y]\nb: [1, 2]\n", string(out))
+}
+
+func TestApplyTo_RemoveOneAtATime(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(`
+items: [a, b, c, d, e, f]
+m: {a: 1, b: 2, c: 3, d: 4}
+`), &node)
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	err = yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Remove one at a time
+  version: 0.0.0
+actions:
+  - target: $.items[1]
+    remove: true
+  - target: $.items[?@ == 'd']
+    remove: true
+  - target: $.items
+    update: [g, h]
+  - target: $.items[?@ == 'g']
+    remove: true
+  - target: $.items[0]
+    remove: true
+  - target: $.items[3]
+    update: i
+  - target: $.m.b
+    remove: true
+  - target: $.m.a
+    remove: true
+  - target: $.m
+    update: {e: 5}
+  - target: $.m.c
+    remove: true
+  - target: $.m.e
+    update: 6
+`), &o)
+	require.NoError(t, err)
+
+	err = o.ApplyTo(&node)
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Equal(t, "items: [c, e, f, i]\nm: {d: 4, e: 6}\n", string(out))
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -12,11 +14,16 @@ import (
 // Compare compares input specifications from two files and returns an overlay
 // that will convert the first into the second.
 func Compare(title string, y1 *yaml.Node, y2 yaml.Node) (*Overlay, error) {
-	actions, err := walkTreesAndCollectActions(simplePath{}, y1, y2)
+	diffs, err := walkTrees(simplePath{}, y1, y2)
 	if err != nil {
 		return nil, err
 	}
 
+	var actions []Action
+	for _, diff := range diffs {
+		actions = append(actions, diff.actions()...)
+	}
+
 	return &Overlay{
 		Version:         "1.0.0",
 		JSONPathVersion: "rfc9535",
@@ -28,6 +35,79 @@ func Compare(title string, y1 *yaml.Node, y2 yaml.Node) (*Overlay, error) {
 	}, nil
 }
 
+type differenceKind int
+
+const (
+	// differenceAdd is a key added to a mapping.
+	differenceAdd differenceKind = iota
+	differenceReplace
+	differenceRemove
+	// differenceAppend is an item appended to a sequence.
+	differenceAppend
+	// differenceReplaceItems is a sequence whose items were all replaced.
+	differenceReplaceItems
+)
+
+// difference is a change found by comparing two trees, from which overlay
+// actions and patches are built.
+type difference struct {
+	kind differenceKind
+	path simplePath
+
+	// key is the key of an added mapping entry. value is the added or
+	// replacing node, the appended item, or the sequence with the new items.
+	key   *yaml.Node
+	value *yaml.Node
+}
+
+func newDifference(kind differenceKind, path simplePath, value *yaml.Node) difference {
+	// paths share their backing arrays as they are built, so keep a copy
+	return difference{kind: kind, path: slices.Clone(path), value: value}
+}
+
+func (d difference) actions() []Action {
+	switch d.kind {
+	case differenceAdd:
+		return []Action{{
+			Target: d.path.Dir().ToJSONPath(),
+			Update: yaml.Node{
+				Kind:    yaml.MappingNode,
+				Content: []*yaml.Node{d.key, d.value},
+			},
+		}}
+	case differenceReplace:
+		return []Action{{
+			Target: d.path.ToJSONPath(),
+			Update: *d.value,
+		}}
+	case differenceRemove:
+		return []Action{{
+			Target: d.path.ToJSONPath(),
+			Remove: true,
+		}}
+	case differenceAppend:
+		return []Action{{
+			Target: d.path.ToJSONPath(),
+			Update: yaml.Node{
+				Kind:    yaml.SequenceNode,
+				Content: []*yaml.Node{d.value},
+			},
+		}}
+	case differenceReplaceItems:
+		return []Action{{
+			Target: d.path.ToJSONPath() + "[*]", // target all elements
+			Remove: true,
+		}, {
+			Target: d.path.ToJSONPath(),
+			Update: yaml.Node{
+				Kind:    yaml.SequenceNode,
+				Content: d.value.Content,
+			},
+		}}
+	}
+	return nil
+}
+
 type simplePart struct {
 	isKey bool
 	key   string
@@ -80,6 +160,24 @@ func (p simplePath) ToJSONPath() string {
 	return out.String()
 }
 
+// ToJSONPointer returns the path as a JSON pointer (RFC 6901) fragment, such as
+// #/components/schemas/Drink.
+func (p simplePath) ToJSONPointer() string {
+	out := &strings.Builder{}
+	out.WriteString("#")
+	for _, part := range p {
+		out.WriteString("/")
+		if part.isKey {
+			out.WriteString(jsonPointerEscaper.Replace(part.key))
+		} else {
+			out.WriteString(strconv.Itoa(part.index))
+		}
+	}
+	return out.String()
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
 func (p simplePath) Dir() simplePath {
 	return p[:len(p)-1]
 }
@@ -88,30 +186,61 @@ func (p simplePath) Base() simplePart {
 	return p[len(p)-1]
 }
 
-func walkTreesAndCollectActions(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([]Action, error) {
-	if y1 == nil {
-		return []Action{{
-			Target: path.Dir().ToJSONPath(),
-			Update: y2,
-		}}, nil
+// Resolve returns the node at this path below the given root, or nil if there
+// is none.
+func (p simplePath) Resolve(root *yaml.Node) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
 	}
+	for _, part := range p {
+		var next *yaml.Node
+		switch {
+		case part.isKey && node.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == part.key {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case !part.isKey && node.Kind == yaml.SequenceNode:
+			if part.index >= 0 && part.index < len(node.Content) {
+				next = node.Content[part.index]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
 
+// HasPrefix reports whether the given path is an ancestor of, or equal to,
+// this path.
+func (p simplePath) HasPrefix(prefix simplePath) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func walkTrees(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([]difference, error) {
 	if y2.IsZero() {
-		return []Action{{
-			Target: path.ToJSONPath(),
-			Remove: true,
-		}}, nil
+		return []difference{newDifference(differenceRemove, path, nil)}, nil
 	}
 	if y1.Kind != y2.Kind {
-		return []Action{{
-			Target: path.ToJSONPath(),
-			Update: y2,
-		}}, nil
+		return []difference{newDifference(differenceReplace, path, &y2)}, nil
 	}
 
 	switch y1.Kind {
 	case yaml.DocumentNode:
-		return walkTreesAndCollectActions(path, y1.Content[0], *y2.Content[0])
+		return walkTrees(path, y1.Content[0], *y2.Content[0])
 	case yaml.SequenceNode:
 		if len(y2.Content) == len(y1.Content) {
 			return walkSequenceNode(path, y1, y2)
@@ -119,33 +248,15 @@ func walkTreesAndCollectActions(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([
 
 		if len(y2.Content) == len(y1.Content)+1 &&
 			yamlEquals(y2.Content[:len(y1.Content)], y1.Content) {
-			return []Action{{
-				Target: path.ToJSONPath(),
-				Update: yaml.Node{
-					Kind:    y1.Kind,
-					Content: []*yaml.Node{y2.Content[len(y1.Content)]},
-				},
-			}}, nil
+			return []difference{newDifference(differenceAppend, path, y2.Content[len(y1.Content)])}, nil
 		}
 
-		return []Action{{
-			Target: path.ToJSONPath() + "[*]", // target all elements
-			Remove: true,
-		}, {
-			Target: path.ToJSONPath(),
-			Update: yaml.Node{
-				Kind:    y1.Kind,
-				Content: y2.Content,
-			},
-		}}, nil
+		return []difference{newDifference(differenceReplaceItems, path, &y2)}, nil
 	case yaml.MappingNode:
 		return walkMappingNode(path, y1, y2)
 	case yaml.ScalarNode:
 		if y1.Value != y2.Value {
-			return []Action{{
-				Target: path.ToJSONPath(),
-				Update: y2,
-			}}, nil
+			return []difference{newDifference(differenceReplace, path, &y2)}, nil
 		}
 	case yaml.AliasNode:
 		log.Println("YAML alias nodes are not yet supported for compare.")
@@ -175,33 +286,24 @@ func yamlEquals(nodes []*yaml.Node, content []*yaml.Node) bool {
 	return true
 }
 
-func walkSequenceNode(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([]Action, error) {
-	nodeLen := max(len(y1.Content), len(y2.Content))
-	var actions []Action
-	for i := 0; i < nodeLen; i++ {
-		var c1, c2 *yaml.Node
-		if i < len(y1.Content) {
-			c1 = y1.Content[i]
-		}
-		if i < len(y2.Content) {
-			c2 = y2.Content[i]
-		}
-
-		newActions, err := walkTreesAndCollectActions(
+func walkSequenceNode(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([]difference, error) {
+	var diffs []difference
+	for i := range y1.Content {
+		newDiffs, err := walkTrees(
 			path.WithIndex(i),
-			c1, *c2)
+			y1.Content[i], *y2.Content[i])
 		if err != nil {
 			return nil, err
 		}
 
-		actions = append(actions, newActions...)
+		diffs = append(diffs, newDiffs...)
 	}
 
-	return actions, nil
+	return diffs, nil
 }
 
-func walkMappingNode(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([]Action, error) {
-	var actions []Action
+func walkMappingNode(path simplePath, y1 *yaml.Node, y2 yaml.Node) ([]difference, error) {
+	var diffs []difference
 	foundKeys := map[string]struct{}{}
 
 	// Add or update keys in y2 that differ/missing from y1
@@ -218,29 +320,21 @@ Outer:
 			v1 := y1.Content[j+1]
 
 			if k1.Value == k2.Value {
-				newActions, err := walkTreesAndCollectActions(
+				newDiffs, err := walkTrees(
 					path.WithKey(k2.Value),
 					v1, *v2)
 				if err != nil {
 					return nil, err
 				}
-				actions = append(actions, newActions...)
+				diffs = append(diffs, newDiffs...)
 				continue Outer
 			}
 		}
 
 		// key not found in y1, so add it
-		newActions, err := walkTreesAndCollectActions(
-			path.WithKey(k2.Value),
-			nil, yaml.Node{
-				Kind:    y1.Kind,
-				Content: []*yaml.Node{k2, v2},
-			})
-		if err != nil {
-			return nil, err
-		}
-
-		actions = append(actions, newActions...)
+		diff := newDifference(differenceAdd, path.WithKey(k2.Value), v2)
+		diff.key = k2
+		diffs = append(diffs, diff)
 	}
 
 	// look for keys in y1 that are not in y2: remove them
@@ -251,11 +345,8 @@ Outer:
 			continue
 		}
 
-		actions = append(actions, Action{
-			Target: path.WithKey(k1.Value).ToJSONPath(),
-			Remove: true,
-		})
+		diffs = append(diffs, newDifference(differenceRemove, path.WithKey(k1.Value), nil))
 	}
 
-	return actions, nil
+	return diffs, nil
 }
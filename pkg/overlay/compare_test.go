@@ -1,10 +1,12 @@
 package overlay_test
 
 import (
+	"encoding/json"
 	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
 	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 	"testing"
 )
 
@@ -37,3 +39,63 @@ func TestCompare(t *testing.T) {
 	NodeMatchesFile(t, node, "testdata/openapi-overlayed.yaml")
 
 }
+
+func TestCompareJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	var y1, y2 yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`{a: 1, b: [1, 2], c: {d: x}, e: [1], f/g: {h: true}}`), &y1))
+	require.NoError(t, yaml.Unmarshal([]byte(`{a: 2, b: [1, 2, 3], c: {i: y}, e: [2, 3], f/g: {h: false}}`), &y2))
+
+	patch, err := overlay.CompareJSONPatch(&y1, y2)
+	require.NoError(t, err)
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op": "replace", "path": "/a", "value": 2},
+		{"op": "add", "path": "/b/-", "value": 3},
+		{"op": "add", "path": "/c/i", "value": "y"},
+		{"op": "remove", "path": "/c/d"},
+		{"op": "replace", "path": "/e", "value": [2, 3]},
+		{"op": "replace", "path": "/f~1g/h", "value": false}
+	]`, string(data))
+
+	mergePatch, err := overlay.CompareMergePatch(&y1, y2)
+	require.NoError(t, err)
+	data, err = json.Marshal(mergePatch)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":2,"b":[1,2,3],"c":{"i":"y","d":null},"e":[2,3],"f/g":{"h":false}}`, string(data), "keys should be kept in order")
+
+	patch, err = overlay.CompareJSONPatch(&y1, y1)
+	require.NoError(t, err)
+	data, err = json.Marshal(patch)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(data))
+}
+
+func TestOverlay_ToJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	expected, err := loader.LoadSpecification("testdata/openapi-overlayed.yaml")
+	require.NoError(t, err)
+	o, err := loader.LoadOverlay("testdata/overlay-generated.yaml")
+	require.NoError(t, err)
+
+	before, err := yaml.Marshal(node)
+	require.NoError(t, err)
+	patch, err := o.ToJSONPatch(node)
+	require.NoError(t, err)
+	after, err := yaml.Marshal(node)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "the spec should be left unchanged")
+
+	comparePatch, err := overlay.CompareJSONPatch(node, *expected)
+	require.NoError(t, err)
+	expectedData, err := json.Marshal(comparePatch)
+	require.NoError(t, err)
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedData), string(data))
+}
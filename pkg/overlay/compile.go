@@ -0,0 +1,359 @@
+package overlay
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CompiledOverlay is an overlay whose targets have all been parsed up front, so
+// that it can be applied to any number of documents without parsing them again.
+// The overlay it was compiled from should not be modified while it is in use.
+type CompiledOverlay struct {
+	overlay *Overlay
+	actions []compiledAction
+
+	// hasFilterExpression is set if any target uses a filter expression.
+	hasFilterExpression bool
+}
+
+type compiledAction struct {
+	Action
+
+	path Queryable
+
+	// customFields are the fields of the action with registered custom action
+	// handlers.
+	customFields []string
+
+	// err is the error from parsing the target, reported when the action is
+	// applied.
+	err error
+
+	// when is the action's x-when condition, if any, and whenErr the error
+	// from parsing it, also reported when the action is applied.
+	when    condition
+	whenErr error
+
+	// onNoMatch and expect are the action's x-on-no-match and
+	// x-expect-matches fields, if any, and matchErr the error from parsing
+	// them.
+	onNoMatch NoMatchPolicy
+	expect    *matchRange
+	matchErr  error
+
+	// warnings are the warnings from parsing the target, reported every time
+	// the action is applied in strict mode.
+	warnings []string
+}
+
+// Compile parses the target of every action of the overlay. It fails if any of
+// the targets is invalid.
+func (o *Overlay) Compile() (*CompiledOverlay, error) {
+	compiled := o.compile()
+
+	errs := make(ValidationErrors, 0)
+	for i, action := range compiled.actions {
+		if action.err != nil {
+			errs = append(errs, fmt.Errorf("overlay action at index %d target %q is invalid: %w", i, action.Target, action.err))
+		}
+		if action.whenErr != nil {
+			errs = append(errs, fmt.Errorf("overlay action at index %d x-when is invalid: %w", i, action.whenErr))
+		}
+		if action.matchErr != nil {
+			errs = append(errs, fmt.Errorf("overlay action at index %d %w", i, action.matchErr))
+		}
+	}
+	if err := errs.Return(); err != nil {
+		return nil, err
+	}
+
+	return compiled, nil
+}
+
+// compile parses the target of every action of the overlay, keeping any errors
+// to be reported when the affected actions are applied.
+func (o *Overlay) compile() *CompiledOverlay {
+	compiled := &CompiledOverlay{
+		overlay: o,
+		actions: make([]compiledAction, len(o.Actions)),
+	}
+	for i, action := range o.Actions {
+		compiled.actions[i] = o.compileAction(action)
+		if targetHasFilterExpression(action.Target) {
+			compiled.hasFilterExpression = true
+		}
+	}
+	return compiled
+}
+
+func (o *Overlay) compileAction(action Action) compiledAction {
+	compiled := compiledAction{Action: action, customFields: customActionFields(action)}
+	compiled.when, compiled.whenErr = actionCondition(action)
+	var err error
+	if compiled.onNoMatch, err = actionNoMatchPolicy(action); err != nil {
+		compiled.matchErr = fmt.Errorf("%s is invalid: %w", OnNoMatchExtension, err)
+	} else if compiled.expect, err = actionExpectedMatches(action); err != nil {
+		compiled.matchErr = fmt.Errorf("%s is invalid: %w", ExpectMatchesExtension, err)
+	}
+	if action.Target == "" {
+		return compiled
+	}
+
+	compiled.path, compiled.err = o.NewPath(action.Target, &compiled.warnings)
+	return compiled
+}
+
+// hasEffect reports whether applying the action can change the document.
+func (a *compiledAction) hasEffect() bool {
+	return a.Target != "" && (a.Remove || !a.Update.IsZero() || len(a.customFields) > 0)
+}
+
+// skipped reports whether the action's x-when condition does not hold for the
+// given variables. Without any variables, no condition holds.
+func (a *compiledAction) skipped(i int, vars map[string]string) (bool, error) {
+	if a.whenErr != nil {
+		return false, fmt.Errorf("overlay action at index %d x-when is invalid: %w", i, a.whenErr)
+	}
+	if a.when == nil {
+		return false, nil
+	}
+	if vars == nil {
+		return true, nil
+	}
+	holds, err := a.when.holds(vars)
+	if err != nil {
+		return false, fmt.Errorf("overlay action at index %d: %w", i, err)
+	}
+	return !holds, nil
+}
+
+// checksMatches reports whether the number of nodes the action's target
+// matches needs to be checked.
+func (a *compiledAction) checksMatches(strict bool) bool {
+	return a.Target != "" && (strict || a.onNoMatch != "" || a.expect != nil)
+}
+
+// checkMatches checks the number of nodes the action's target matched. An
+// x-on-no-match policy decides what to do when there are none, and otherwise
+// x-expect-matches or, failing that, whether the overlay is applied strictly.
+// It returns a warning for the NoMatchWarn policy.
+func (a *compiledAction) checkMatches(nodes []*yaml.Node, strict bool) (string, error) {
+	if len(nodes) == 0 && a.onNoMatch != "" {
+		return validateSelectorHasAtLeastOneTarget(a.Action, nodes, a.onNoMatch)
+	}
+	if a.expect != nil {
+		if !a.expect.contains(len(nodes)) {
+			return "", fmt.Errorf("selector %q matched %d targets, expected %s", a.Target, len(nodes), a.expect)
+		}
+		return "", nil
+	}
+	policy := NoMatchIgnore
+	if strict {
+		policy = NoMatchError
+	}
+	return validateSelectorHasAtLeastOneTarget(a.Action, nodes, policy)
+}
+
+// query returns the nodes selected by the action's target.
+func (a *compiledAction) query(root *yaml.Node) ([]*yaml.Node, error) {
+	if a.Target == "" {
+		return nil, nil
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a.path.Query(root), nil
+}
+
+// applyTo applies the action to the given nodes, which must have been selected
+// by its target.
+func (a *compiledAction) applyTo(state *applyState, nodes []*yaml.Node, warnings *[]string) error {
+	if err := applyCustomActions(state, &a.Action, a.customFields, nodes); err != nil {
+		return err
+	}
+
+	if a.Remove {
+		return applyRemoveAction(state, nodes)
+	}
+	if a.Update.IsZero() {
+		return nil
+	}
+	action := a.Action
+	if state.vars != nil {
+		update, err := interpolate(&a.Update, state.vars)
+		if err != nil {
+			return fmt.Errorf("failed to interpolate update for target %q: %w", a.Target, err)
+		}
+		action.Update = *update
+	}
+	return applyUpdateAction(state, action, nodes, warnings)
+}
+
+// ApplyTo will apply the compiled overlay's changes to the given YAML document.
+func (c *CompiledOverlay) ApplyTo(root *yaml.Node) error {
+	_, err := c.ApplyToContext(context.Background(), root, nil)
+	return err
+}
+
+// ApplyToStrict will apply the compiled overlay's changes to the given YAML
+// document, failing if any selector matches nothing. Each target is only
+// queried once.
+func (c *CompiledOverlay) ApplyToStrict(root *yaml.Node) (error, []string) {
+	result, err := c.ApplyToContext(context.Background(), root, &ApplyOptions{Strict: true})
+	return err, result.Warnings
+}
+
+// ApplyToContext will apply the compiled overlay's changes to the given YAML
+// document, as configured by the options, which may be nil. The context is
+// checked before each action's target is queried and while large numbers of
+// matched nodes are modified, but a single query cannot be interrupted, so a
+// slow JSONPath expression delays cancellation until it completes. If the
+// context is cancelled, the document is left with the changes made so far and
+// the context's error is returned.
+//
+// The result is returned even when applying fails, so that the warnings
+// collected up to that point are available.
+func (c *CompiledOverlay) ApplyToContext(ctx context.Context, root *yaml.Node, opts *ApplyOptions) (*ApplyResult, error) {
+	if opts == nil {
+		opts = &ApplyOptions{}
+	}
+
+	policy, err := ParseDanglingRefPolicy(string(opts.DanglingRefs))
+	if err != nil {
+		return &ApplyResult{Warnings: []string{}}, err
+	}
+	var refs map[*yaml.Node]refSnapshot
+	if policy != DanglingRefsIgnore {
+		refs = snapshotRefs(root)
+	}
+
+	var matches *actionMatches
+	var violationsBefore []SpecViolation
+	if opts.ValidateSpec {
+		matches = &actionMatches{}
+		violationsBefore, err = ValidateSpecification(root)
+		if err != nil {
+			return &ApplyResult{Warnings: []string{}}, err
+		}
+	}
+
+	result := &ApplyResult{Warnings: []string{}}
+	multiError := []string{}
+	state := newApplyState(root)
+	state.ctx = ctx
+	state.keepComments = opts.MinimalDiff
+	if opts.Interpolate {
+		state.vars = opts.Vars
+		if state.vars == nil {
+			state.vars = map[string]string{}
+		}
+	}
+	var changes *provenance
+	if opts.SourceMap || opts.ProvenanceComments {
+		changes = newProvenance()
+		state.touched = changes.touch
+	}
+	for i := range c.actions {
+		action := &c.actions[i]
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		skipped, err := action.skipped(i, opts.Vars)
+		if err != nil {
+			return result, err
+		}
+		if action.matchErr != nil {
+			return result, fmt.Errorf("overlay action at index %d %w", i, action.matchErr)
+		}
+		if skipped {
+			result.Skipped = append(result.Skipped, SkippedAction{Index: i, Description: action.Description})
+			opts.notify(ActionEvent{Kind: ActionSkipped, Index: i, Total: len(c.actions), Action: &action.Action})
+			continue
+		}
+
+		opts.notify(ActionEvent{Kind: ActionStarted, Index: i, Total: len(c.actions), Action: &action.Action})
+
+		actionWarnings := []string{}
+		var nodes []*yaml.Node
+		var actionErr error
+		if action.checksMatches(opts.Strict) || action.hasEffect() {
+			// the observer may have taken a while
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			nodes, actionErr = action.query(root)
+		}
+		if action.checksMatches(opts.Strict) && actionErr == nil {
+			var warning string
+			warning, actionErr = action.checkMatches(nodes, opts.Strict)
+			if warning != "" {
+				actionWarnings = append(actionWarnings, warning)
+			}
+		}
+		if opts.Strict && actionErr != nil {
+			multiError = append(multiError, actionErr.Error())
+		}
+		if action.hasEffect() && (opts.Strict || actionErr == nil) {
+			if opts.Strict {
+				actionWarnings = append(actionWarnings, action.warnings...)
+			}
+			if action.err == nil {
+				if changes != nil {
+					changes.action = i
+				}
+				matches.record(state, i, action.Action, nodes)
+				applyWarnings := []string{}
+				if err := action.applyTo(state, nodes, &applyWarnings); err != nil {
+					return result, err
+				}
+				if opts.Strict {
+					actionWarnings = append(actionWarnings, applyWarnings...)
+				}
+			}
+		}
+
+		for _, warning := range actionWarnings {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("update action (%v / %v) target=%s: %s", i+1, len(c.actions), action.Target, warning))
+		}
+
+		opts.notify(ActionEvent{Kind: ActionFinished, Index: i, Total: len(c.actions), Action: &action.Action, Matches: len(nodes), Err: actionErr})
+
+		if actionErr != nil && !opts.Strict {
+			return result, actionErr
+		}
+	}
+
+	if refs != nil {
+		result.DanglingRefs = handleDanglingRefs(root, refs, policy)
+	}
+
+	if changes != nil {
+		if opts.SourceMap {
+			result.SourceMap = changes.sourceMap(root, c.actions)
+		}
+		if opts.ProvenanceComments {
+			changes.annotate(root, c.actions)
+		}
+	}
+
+	if matches != nil {
+		result.SpecViolations, err = ValidateSpecification(root)
+		if err != nil {
+			return result, err
+		}
+		matches.attribute(root, result.SpecViolations, violationsBefore)
+	}
+
+	if opts.Strict && c.hasFilterExpression && !c.overlay.UsesRFC9535() {
+		result.Warnings = append(result.Warnings, "overlay has a filter expression but lacks `x-speakeasy-jsonpath: rfc9535` extension. Deprecated jsonpath behaviour in use. See overlay.speakeasy.com for the implementation playground.")
+	}
+
+	if len(multiError) > 0 {
+		return result, fmt.Errorf("error applying overlay (strict): %v", strings.Join(multiError, ","))
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	o, err := loader.LoadOverlay("testdata/overlay.yaml")
+	require.NoError(t, err)
+
+	compiled, err := o.Compile()
+	require.NoError(t, err)
+
+	// a compiled overlay can be applied to many documents
+	for i := 0; i < 3; i++ {
+		node, err := loader.LoadSpecification("testdata/openapi.yaml")
+		require.NoError(t, err)
+
+		err = compiled.ApplyTo(node)
+		require.NoError(t, err)
+		NodeMatchesFile(t, node, "testdata/openapi-overlayed.yaml")
+	}
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	err, warnings := compiled.ApplyToStrict(node)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	NodeMatchesFile(t, node, "testdata/openapi-overlayed.yaml")
+}
+
+func TestCompile_InvalidTarget(t *testing.T) {
+	t.Parallel()
+
+	o, err := loader.LoadOverlay("testdata/overlay-old.yaml")
+	require.NoError(t, err)
+	o.JSONPathVersion = "rfc9535"
+
+	_, err = o.Compile()
+	assert.ErrorContains(t, err, "overlay action at index 0 target \"$.paths.*[?(@.x-my-ignore)]\" is invalid")
+}
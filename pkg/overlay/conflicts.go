@@ -0,0 +1,317 @@
+package overlay
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConflictKind identifies the kind of problem found between overlay actions.
+type ConflictKind string
+
+const (
+	// ConflictOverwrite is reported when an action writes a different value to
+	// a node that an earlier action already wrote.
+	ConflictOverwrite ConflictKind = "overwrite"
+	// ConflictDeadAction is reported when an action matches nothing at the time
+	// it is applied, unless its x-on-no-match policy is ignore.
+	ConflictDeadAction ConflictKind = "dead-action"
+	// ConflictUndo is reported when an action removes a node written by an
+	// earlier action, or restores a value an earlier action changed.
+	ConflictUndo ConflictKind = "undo"
+	// ConflictOrderDependent is reported when an action matches different
+	// nodes than it would in the original document because of earlier actions.
+	ConflictOrderDependent ConflictKind = "order-dependent"
+)
+
+// Conflict describes a problem found between overlay actions.
+type Conflict struct {
+	Kind ConflictKind `json:"kind"`
+
+	// Action is the index of the action the conflict is reported for.
+	Action int `json:"action"`
+
+	// Other is the index of the earlier action involved, or -1 if there is none.
+	Other int `json:"other"`
+
+	// Path is the normalized JSONPath of the node in conflict, if any.
+	Path string `json:"path,omitempty"`
+
+	// Message describes the conflict.
+	Message string `json:"message"`
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("action at index %d (%s): %s", c.Action, c.Kind, c.Message)
+}
+
+type conflictWrite struct {
+	action int
+	path   simplePath
+	value  string
+	append bool
+	when   condition
+}
+
+type conflictRemoval struct {
+	action int
+	path   simplePath
+	when   condition
+}
+
+// DetectConflicts evaluates every action of the overlay against a copy of the
+// given specification, in order, and reports actions that overwrite or undo
+// each other, actions that match nothing, and actions whose matches depend on
+// the actions applied before them. The given specification is not modified.
+//
+// Every action is applied, whatever its x-when condition, but actions are not
+// reported as overwriting or undoing each other if their conditions cannot
+// both hold.
+func (o *Overlay) DetectConflicts(root *yaml.Node) ([]Conflict, error) {
+	var conflicts []Conflict
+	working := clone(root)
+	state := newApplyState(working)
+	originalIdx := newParentIndex(root)
+	writes := map[string]conflictWrite{}
+	var removals []conflictRemoval
+
+	for i, action := range o.Actions {
+		if action.Target == "" {
+			continue
+		}
+		// an invalid condition is reported by Validate
+		when, _ := actionCondition(action)
+
+		nodes, err := o.queryTarget(working, action)
+		if err != nil {
+			return nil, err
+		}
+		originalNodes, err := o.queryTarget(root, action)
+		if err != nil {
+			return nil, err
+		}
+
+		workingIdx := state.parents()
+		paths := make([]simplePath, len(nodes))
+		for j, node := range nodes {
+			paths[j] = workingIdx.pathTo(node)
+		}
+		originalPaths := make([]simplePath, len(originalNodes))
+		for j, node := range originalNodes {
+			originalPaths[j] = originalIdx.pathTo(node)
+		}
+
+		if len(paths) == 0 {
+			// an action that may match nothing is optional by design, and an
+			// invalid policy is reported by Validate
+			if policy, _ := actionNoMatchPolicy(action); policy == NoMatchIgnore {
+				continue
+			}
+			if conflict, ok := deadActionConflict(i, when, originalPaths, removals); ok {
+				conflicts = append(conflicts, conflict)
+			}
+			continue
+		}
+
+		if differing := symmetricDifference(paths, originalPaths); len(differing) > 0 {
+			other := lastActionAffecting(differing, writes, removals)
+			message := "matches different nodes than in the original document"
+			if other >= 0 {
+				message += fmt.Sprintf(" because of the action at index %d", other)
+			}
+			conflicts = append(conflicts, Conflict{
+				Kind:    ConflictOrderDependent,
+				Action:  i,
+				Other:   other,
+				Path:    differing[0].ToJSONPath(),
+				Message: message,
+			})
+		}
+
+		if action.Remove {
+			for _, path := range paths {
+				reported := map[int]struct{}{}
+				for _, key := range sortedKeys(writes) {
+					write := writes[key]
+					if !write.path.HasPrefix(path) || !write.when.compatible(when) {
+						continue
+					}
+					delete(writes, key)
+					if _, ok := reported[write.action]; ok {
+						continue
+					}
+					reported[write.action] = struct{}{}
+					conflicts = append(conflicts, Conflict{
+						Kind:    ConflictUndo,
+						Action:  i,
+						Other:   write.action,
+						Path:    path.ToJSONPath(),
+						Message: fmt.Sprintf("removes %s, which was written by the action at index %d", path.ToJSONPath(), write.action),
+					})
+				}
+				removals = append(removals, conflictRemoval{action: i, path: path, when: when})
+			}
+		} else if !action.Update.IsZero() {
+			for j, node := range nodes {
+				collectWrites(paths[j], node, &action.Update, func(path simplePath, value *yaml.Node, isAppend bool) {
+					key := path.ToJSONPath()
+					write := conflictWrite{action: i, path: path, value: nodeValue(value), append: isAppend, when: when}
+					if prior, ok := writes[key]; ok && !isAppend && !prior.append && prior.value != write.value && prior.when.compatible(when) {
+						if original := path.Resolve(root); original != nil && nodeValue(original) == write.value {
+							conflicts = append(conflicts, Conflict{
+								Kind:    ConflictUndo,
+								Action:  i,
+								Other:   prior.action,
+								Path:    key,
+								Message: fmt.Sprintf("restores the original value of %s, which was changed by the action at index %d", key, prior.action),
+							})
+						} else {
+							conflicts = append(conflicts, Conflict{
+								Kind:    ConflictOverwrite,
+								Action:  i,
+								Other:   prior.action,
+								Path:    key,
+								Message: fmt.Sprintf("overwrites the value of %s written by the action at index %d", key, prior.action),
+							})
+						}
+					}
+					writes[key] = write
+				})
+			}
+		}
+
+		if err := o.applyAction(state, action); err != nil {
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// deadActionConflict explains why the action matched nothing. It reports no
+// conflict if what the action matched was removed by an action whose x-when
+// condition cannot hold along with its own.
+func deadActionConflict(action int, when condition, originalPaths []simplePath, removals []conflictRemoval) (Conflict, bool) {
+	for i := len(removals) - 1; i >= 0; i-- {
+		for _, path := range originalPaths {
+			if path.HasPrefix(removals[i].path) {
+				if !removals[i].when.compatible(when) {
+					return Conflict{}, false
+				}
+				return Conflict{
+					Kind:    ConflictDeadAction,
+					Action:  action,
+					Other:   removals[i].action,
+					Path:    path.ToJSONPath(),
+					Message: fmt.Sprintf("matches nothing because %s was removed by the action at index %d", removals[i].path.ToJSONPath(), removals[i].action),
+				}, true
+			}
+		}
+	}
+
+	return Conflict{
+		Kind:    ConflictDeadAction,
+		Action:  action,
+		Other:   -1,
+		Message: "matches nothing",
+	}, true
+}
+
+// collectWrites calls write for every node that merging update into node would
+// replace or append to.
+func collectWrites(path simplePath, node *yaml.Node, update *yaml.Node, write func(path simplePath, value *yaml.Node, isAppend bool)) {
+	if node == nil || node.Kind != update.Kind {
+		write(path, update, false)
+		return
+	}
+
+	switch update.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(update.Content); i += 2 {
+			key := update.Content[i].Value
+			var child *yaml.Node
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == key {
+					child = node.Content[j+1]
+					break
+				}
+			}
+			collectWrites(append(path[:len(path):len(path)], keyPart(key)), child, update.Content[i+1], write)
+		}
+	case yaml.SequenceNode:
+		write(path, update, true)
+	default:
+		write(path, update, false)
+	}
+}
+
+// symmetricDifference returns the paths found in only one of the given lists.
+func symmetricDifference(a, b []simplePath) []simplePath {
+	inA := map[string]struct{}{}
+	for _, path := range a {
+		inA[path.ToJSONPath()] = struct{}{}
+	}
+	inB := map[string]struct{}{}
+	for _, path := range b {
+		inB[path.ToJSONPath()] = struct{}{}
+	}
+
+	var out []simplePath
+	for _, path := range a {
+		if _, ok := inB[path.ToJSONPath()]; !ok {
+			out = append(out, path)
+		}
+	}
+	for _, path := range b {
+		if _, ok := inA[path.ToJSONPath()]; !ok {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// lastActionAffecting returns the index of the last recorded action that wrote
+// or removed one of the given paths, one of their ancestors or descendants, or
+// a sibling of any of those, which a filter expression may have looked at.
+func lastActionAffecting(paths []simplePath, writes map[string]conflictWrite, removals []conflictRemoval) int {
+	last := -1
+	related := func(other simplePath) bool {
+		for _, path := range paths {
+			if len(other) > 0 && path.HasPrefix(other.Dir()) || other.HasPrefix(path) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, write := range writes {
+		if write.action > last && related(write.path) {
+			last = write.action
+		}
+	}
+	for _, removal := range removals {
+		if removal.action > last && related(removal.path) {
+			last = removal.action
+		}
+	}
+	return last
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nodeValue returns a representation of the node's decoded value, so that nodes
+// that only differ in style or comments compare equal.
+func nodeValue(node *yaml.Node) string {
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%#v", value)
+}
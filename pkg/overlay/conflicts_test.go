@@ -0,0 +1,48 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectConflicts(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay-conflicts.yaml")
+	require.NoError(t, err)
+
+	conflicts, err := o.DetectConflicts(node)
+	require.NoError(t, err)
+
+	type summary struct {
+		Kind   overlay.ConflictKind
+		Action int
+		Other  int
+		Path   string
+	}
+	var actual []summary
+	for _, c := range conflicts {
+		actual = append(actual, summary{c.Kind, c.Action, c.Other, c.Path})
+	}
+
+	assert.Equal(t, []summary{
+		{overlay.ConflictOverwrite, 1, 0, `$["info"]["title"]`},
+		{overlay.ConflictUndo, 2, 1, `$["info"]["title"]`},
+		{overlay.ConflictUndo, 4, 3, `$["paths"]["/drinks"]`},
+		{overlay.ConflictDeadAction, 5, 4, `$["paths"]["/drinks"]["get"]["summary"]`},
+		{overlay.ConflictDeadAction, 6, -1, ``},
+		{overlay.ConflictOrderDependent, 8, 7, `$["paths"]["/ingredients"]["get"]`},
+	}, actual)
+
+	// the spec is left untouched
+	original, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, original, node)
+}
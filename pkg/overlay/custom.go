@@ -0,0 +1,181 @@
+package overlay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CustomAction handles an action field that the overlay specification does not
+// define, such as x-sort-keys. Once registered, any action that sets the field
+// runs the handler on every node its target selects, before the built-in update
+// or remove is applied.
+type CustomAction struct {
+	// Validate, if set, checks the value of the field. It is called by
+	// Overlay.Validate.
+	Validate func(value any) error
+
+	// Apply performs the custom action on a single node.
+	Apply func(ctx *CustomActionContext) error
+}
+
+// CustomActionContext is passed to CustomAction.Apply for each selected node.
+type CustomActionContext struct {
+	// Root is the document the overlay is being applied to.
+	Root *yaml.Node
+
+	// Node is the node selected by the action's target.
+	Node *yaml.Node
+
+	// Parent is the node containing Node, or nil if Node is the root.
+	Parent *yaml.Node
+
+	// Action is the action being applied.
+	Action *Action
+
+	// Field is the name of the field that triggered the custom action, and
+	// Value is its value.
+	Field string
+	Value any
+
+	idx     *parentIndex
+	touched func(node *yaml.Node)
+}
+
+// Changed tells the overlay that the content of the given node was changed,
+// such as by adding, removing or reordering its children, so that it can keep
+// track of where each node of the document is. Apply must call it for every
+// node whose Content it changes. It may also be called for other nodes that
+// were changed, such as scalars, for them to be attributed to the action in
+// source maps and provenance comments.
+func (ctx *CustomActionContext) Changed(node *yaml.Node) {
+	ctx.idx.reindexChildren(node)
+	ctx.touch(node)
+}
+
+func (ctx *CustomActionContext) touch(node *yaml.Node) {
+	if ctx.touched != nil {
+		ctx.touched(node)
+	}
+}
+
+var customActions = struct {
+	sync.RWMutex
+	handlers map[string]CustomAction
+}{handlers: map[string]CustomAction{}}
+
+// RegisterCustomAction registers a handler for actions that set the given
+// field, which must start with "x-".
+func RegisterCustomAction(field string, action CustomAction) error {
+	if !strings.HasPrefix(field, "x-") {
+		return fmt.Errorf("custom action field %q must start with x-", field)
+	}
+	if action.Apply == nil {
+		return fmt.Errorf("custom action %q must define Apply", field)
+	}
+
+	customActions.Lock()
+	defer customActions.Unlock()
+	if _, exists := customActions.handlers[field]; exists {
+		return fmt.Errorf("custom action %q is already registered", field)
+	}
+	customActions.handlers[field] = action
+	return nil
+}
+
+// UnregisterCustomAction removes the handler registered for the given field,
+// if any.
+func UnregisterCustomAction(field string) {
+	customActions.Lock()
+	defer customActions.Unlock()
+	delete(customActions.handlers, field)
+}
+
+// IsCustomActionRegistered reports whether a handler is registered for the
+// given field.
+func IsCustomActionRegistered(field string) bool {
+	customActions.RLock()
+	defer customActions.RUnlock()
+	_, ok := customActions.handlers[field]
+	return ok
+}
+
+// customActionFields returns the fields of the action that have registered
+// handlers, in a stable order.
+func customActionFields(action Action) []string {
+	customActions.RLock()
+	defer customActions.RUnlock()
+
+	var fields []string
+	for field := range action.Extensions {
+		if _, ok := customActions.handlers[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func lookupCustomAction(field string) (CustomAction, bool) {
+	customActions.RLock()
+	defer customActions.RUnlock()
+	action, ok := customActions.handlers[field]
+	return action, ok
+}
+
+// applyCustomActions runs the handlers for the given fields of the action on
+// each of the nodes.
+func applyCustomActions(state *applyState, action *Action, fields []string, nodes []*yaml.Node) error {
+	for _, field := range fields {
+		handler, ok := lookupCustomAction(field)
+		if !ok {
+			return fmt.Errorf("custom action %q is no longer registered", field)
+		}
+
+		idx := state.parents()
+		for i, node := range nodes {
+			if err := checkContext(state.ctx, i); err != nil {
+				return err
+			}
+
+			err := handler.Apply(&CustomActionContext{
+				Root:    state.root,
+				Node:    node,
+				Parent:  idx.getParent(node),
+				Action:  action,
+				Field:   field,
+				Value:   action.Extensions[field],
+				idx:     idx,
+				touched: state.touched,
+			})
+			if err != nil {
+				return fmt.Errorf("custom action %s failed on target %q: %w", field, action.Target, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCustomActions checks the values of the custom action fields set on
+// the action.
+func validateCustomActions(i int, action Action) []error {
+	var errs []error
+	fields := customActionFields(action)
+	for _, field := range fields {
+		handler, _ := lookupCustomAction(field)
+		if handler.Validate == nil {
+			continue
+		}
+		if err := handler.Validate(action.Extensions[field]); err != nil {
+			errs = append(errs, fmt.Errorf("overlay action at index %d has an invalid %s: %w", i, field, err))
+		}
+	}
+	if len(fields) > 0 && action.Remove {
+		errs = append(errs, fmt.Errorf("overlay action at index %d should not both set remove and use custom action %s", i, strings.Join(fields, ", ")))
+	}
+	return errs
+}
@@ -0,0 +1,89 @@
+package overlay_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type keyValue struct{ key, value *yaml.Node }
+
+func TestCustomAction(t *testing.T) {
+	t.Parallel()
+
+	err := overlay.RegisterCustomAction("x-test-sort-keys", overlay.CustomAction{
+		Validate: func(value any) error {
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("expected a boolean")
+			}
+			return nil
+		},
+		Apply: func(ctx *overlay.CustomActionContext) error {
+			if ctx.Value != true || ctx.Node.Kind != yaml.MappingNode {
+				return nil
+			}
+			var pairs []keyValue
+			for i := 0; i+1 < len(ctx.Node.Content); i += 2 {
+				pairs = append(pairs, keyValue{ctx.Node.Content[i], ctx.Node.Content[i+1]})
+			}
+			sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].key.Value < pairs[j].key.Value })
+			ctx.Node.Content = ctx.Node.Content[:0]
+			for _, pair := range pairs {
+				ctx.Node.Content = append(ctx.Node.Content, pair.key, pair.value)
+			}
+			ctx.Changed(ctx.Node)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { overlay.UnregisterCustomAction("x-test-sort-keys") })
+
+	err = overlay.RegisterCustomAction("x-test-sort-keys", overlay.CustomAction{Apply: func(*overlay.CustomActionContext) error { return nil }})
+	assert.ErrorContains(t, err, "already registered")
+	err = overlay.RegisterCustomAction("sort-keys", overlay.CustomAction{Apply: func(*overlay.CustomActionContext) error { return nil }})
+	assert.ErrorContains(t, err, "must start with x-")
+	assert.True(t, overlay.IsCustomActionRegistered("x-test-sort-keys"))
+
+	var node yaml.Node
+	err = yaml.Unmarshal([]byte("b: 1\nc: {z: 1, y: 2}\na: 3\n"), &node)
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	err = yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Custom actions
+  version: 0.0.0
+actions:
+  - target: $
+    x-test-sort-keys: true
+  - target: $.c
+    x-test-sort-keys: true
+    update:
+      x: 0
+  - target: $.c.z
+    remove: true
+`), &o)
+	require.NoError(t, err)
+	require.NoError(t, o.Validate())
+
+	err = o.ApplyTo(&node)
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Equal(t, "a: 3\nb: 1\nc: {y: 2, x: 0}\n", string(out))
+
+	o.Actions[0].Extensions["x-test-sort-keys"] = "yes"
+	o.Actions[1].Remove = true
+	o.Actions[1].Update = yaml.Node{}
+	err = o.Validate()
+	assert.ErrorContains(t, err, "overlay action at index 0 has an invalid x-test-sort-keys: expected a boolean")
+	assert.ErrorContains(t, err, "overlay action at index 1 should not both set remove and use custom action x-test-sort-keys")
+}
@@ -0,0 +1,496 @@
+package overlay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a YAML document along with the text it was parsed from. Once
+// Root has been changed, such as by applying an overlay to it, Encode writes
+// it out with the original text of everything the changes did not touch, so
+// that comments, quoting, flow or block style, indentation and blank lines are
+// all kept and only what changed differs.
+type Document struct {
+	Root *yaml.Node
+
+	// lines are the lines of the source, each with its line break.
+	lines [][]byte
+
+	// original holds a copy of each node as it was parsed.
+	original map[*yaml.Node]yaml.Node
+
+	// indent is the indentation the source uses for nested block collections.
+	indent int
+
+	// crlf is set if the source's lines end with \r\n, which new text then
+	// does as well.
+	crlf bool
+}
+
+// ParseDocument parses the YAML or JSON document in data, which must hold a
+// single document. Use ParseDocuments for a stream of several.
+func ParseDocument(data []byte) (*Document, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var root yaml.Node
+	if err := dec.Decode(&root); err != nil && err != io.EOF {
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("expected a single document, but found more than one")
+	}
+
+	d := &Document{
+		Root:     &root,
+		lines:    bytes.SplitAfter(data, []byte("\n")),
+		original: map[*yaml.Node]yaml.Node{},
+		indent:   2,
+	}
+	if last := len(d.lines) - 1; len(d.lines[last]) == 0 {
+		d.lines = d.lines[:last]
+	}
+	d.crlf = len(d.lines) > 0 && bytes.HasSuffix(d.lines[0], []byte("\r\n"))
+	d.snapshot(&root)
+	if indent := detectIndent(&root); indent > 0 {
+		d.indent = indent
+	}
+	return d, nil
+}
+
+// ParseDocuments parses each document of a YAML stream, keeping the text of
+// each separately so that they can all be written back out with Encode.
+func ParseDocuments(data []byte) ([]*Document, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	count := 0
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		count++
+	}
+
+	// each document starts at a --- line, unless it is the first, and takes
+	// any comments and directives before it along with it
+	var chunks [][]byte
+	start, hasContent := 0, false
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		if isDocumentStart(line) && hasContent {
+			chunks = append(chunks, bytes.Join(lines[start:i], nil))
+			start, hasContent = i, false
+		}
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 && !isDocumentStart(line) && trimmed[0] != '#' && trimmed[0] != '%' {
+			hasContent = true
+		}
+	}
+	if hasContent || len(chunks) == 0 {
+		chunks = append(chunks, bytes.Join(lines[start:], nil))
+	} else {
+		last := len(chunks) - 1
+		chunks[last] = append(chunks[last], bytes.Join(lines[start:], nil)...)
+	}
+	if len(chunks) != max(count, 1) {
+		return nil, fmt.Errorf("found %d documents, but could not tell where each of them starts", count)
+	}
+
+	docs := make([]*Document, len(chunks))
+	for i, chunk := range chunks {
+		doc, err := ParseDocument(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
+
+func isDocumentStart(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("---")) && (len(line) == 3 || line[3] == ' ' || line[3] == '\t' || line[3] == '\n' || line[3] == '\r')
+}
+
+// SelectDocument returns the index of the document the overlay applies to, out
+// of a specification made up of the given number of documents.
+func (o *Overlay) SelectDocument(count int) (int, error) {
+	if o.Document == nil {
+		if count > 1 {
+			return 0, fmt.Errorf("the specification has %d documents: set x-speakeasy-document to the index of the one the overlay applies to", count)
+		}
+		return 0, nil
+	}
+	if *o.Document < 0 || *o.Document >= count {
+		return 0, fmt.Errorf("x-speakeasy-document is %d, but the specification has %d documents", *o.Document, count)
+	}
+	return *o.Document, nil
+}
+
+func (d *Document) snapshot(node *yaml.Node) {
+	copied := *node
+	copied.Content = slices.Clone(node.Content)
+	d.original[node] = copied
+	for _, child := range node.Content {
+		d.snapshot(child)
+	}
+}
+
+// detectIndent returns how far the first nested block mapping is indented
+// from its key, or 0 if there is none.
+func detectIndent(node *yaml.Node) int {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind == yaml.MappingNode && isBlock(value) && value.Line > key.Line {
+				return value.Column - key.Column
+			}
+		}
+	}
+	for _, child := range node.Content {
+		if indent := detectIndent(child); indent > 0 {
+			return indent
+		}
+	}
+	return 0
+}
+
+// Encode writes the document out. If the structure of the document changed
+// so much that no original text can be kept, it is encoded as a whole.
+func (d *Document) Encode(w io.Writer) error {
+	r := &documentRenderer{Document: d, unchanged: map[*yaml.Node]bool{}}
+	if out, ok := r.render(); ok {
+		_, err := w.Write(out)
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(d.indent)
+	if err := enc.Encode(d.Root); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err := w.Write(d.lineBreaks(buf.Bytes()))
+	return err
+}
+
+// lineBreaks ends the lines of newly encoded text as the source's lines end.
+func (d *Document) lineBreaks(text []byte) []byte {
+	if !d.crlf {
+		return text
+	}
+	return bytes.ReplaceAll(text, []byte("\n"), []byte("\r\n"))
+}
+
+type documentRenderer struct {
+	*Document
+
+	// unchanged caches whether each node and all of its descendants are as
+	// they were parsed.
+	unchanged map[*yaml.Node]bool
+}
+
+func (r *documentRenderer) render() ([]byte, bool) {
+	root := r.Root
+	if r.isUnchanged(root) {
+		return bytes.Join(r.lines, nil), true
+	}
+	original, ok := r.original[root]
+	if !ok || root.Kind != yaml.DocumentNode || !sameNode(root, &original) || root.Content[0] != original.Content[0] {
+		return nil, false
+	}
+	return r.renderBlock(root.Content[0], 0, len(r.lines))
+}
+
+func (r *documentRenderer) isUnchanged(node *yaml.Node) bool {
+	if unchanged, ok := r.unchanged[node]; ok {
+		return unchanged
+	}
+	original, ok := r.original[node]
+	unchanged := ok && sameNode(node, &original)
+	for i := 0; unchanged && i < len(node.Content); i++ {
+		unchanged = node.Content[i] == original.Content[i] && r.isUnchanged(node.Content[i])
+	}
+	r.unchanged[node] = unchanged
+	return unchanged
+}
+
+// sameNode reports whether the nodes are the same, other than their content.
+func sameNode(a, b *yaml.Node) bool {
+	return a.Kind == b.Kind && a.Style == b.Style && a.Tag == b.Tag && a.Value == b.Value &&
+		a.Anchor == b.Anchor && a.Alias == b.Alias && a.HeadComment == b.HeadComment &&
+		a.LineComment == b.LineComment && a.FootComment == b.FootComment && len(a.Content) == len(b.Content)
+}
+
+func isBlock(node *yaml.Node) bool {
+	return (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && node.Style&yaml.FlowStyle == 0
+}
+
+// sourceEntry is an entry of a block collection as it was parsed: a key and
+// value of a mapping, or an item of a sequence.
+type sourceEntry struct {
+	nodes []*yaml.Node
+
+	// start is the first line of the entry, including any comments above it
+	// at the same indentation. The entry runs up to the start of the next.
+	start, end int
+
+	// trailing is where the blank lines and less indented comments at the end
+	// of the entry start. They separate the entry from whatever follows it
+	// rather than belong to it.
+	trailing int
+}
+
+// renderBlock renders the block collection, which was parsed from the lines
+// between from and to.
+func (r *documentRenderer) renderBlock(node *yaml.Node, from, to int) ([]byte, bool) {
+	original, ok := r.original[node]
+	if !ok || !isBlock(node) || !isBlock(&original) || node.Kind != original.Kind || node.Tag != original.Tag ||
+		node.Anchor != original.Anchor || node.HeadComment != original.HeadComment || node.LineComment != original.LineComment ||
+		node.FootComment != original.FootComment || len(original.Content) == 0 || len(node.Content) == 0 {
+		// an empty collection has to be written as {} or [], so is encoded
+		return nil, false
+	}
+
+	width, column := 1, original.Column-1
+	if node.Kind == yaml.MappingNode {
+		width = 2
+	}
+	entries, ok := r.sourceEntries(&original, width, column, from, to)
+	if !ok {
+		return nil, false
+	}
+	byFirst := map[*yaml.Node]int{}
+	for i, entry := range entries {
+		byFirst[entry.nodes[0]] = i
+	}
+
+	out := &bytes.Buffer{}
+	r.copyLines(out, from, entries[0].start)
+	var pending []byte
+	next := 0
+	for i := 0; i+width <= len(node.Content); i += width {
+		nodes := node.Content[i : i+width]
+		j, ok := byFirst[nodes[0]]
+		if !ok || j < next {
+			rendered, ok := r.encodeEntry(node.Kind, nodes, column, true)
+			if !ok {
+				return nil, false
+			}
+			if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+				// the source did not end with a line break
+				out.Write(r.lineBreaks([]byte("\n")))
+			}
+			out.Write(rendered)
+			continue
+		}
+
+		for _, removed := range entries[next:j] {
+			if !r.ownsLine(removed, column) {
+				return nil, false
+			}
+			pending = r.separator(pending, removed)
+		}
+		out.Write(pending)
+		entry := entries[j]
+		rendered, ok := r.renderEntry(node.Kind, nodes, entry, column)
+		if !ok {
+			return nil, false
+		}
+		out.Write(rendered)
+		pending = r.linesBetween(entry.trailing, entry.end)
+		next = j + 1
+	}
+	for _, removed := range entries[next:] {
+		if !r.ownsLine(removed, column) {
+			return nil, false
+		}
+		pending = r.separator(pending, removed)
+	}
+	out.Write(pending)
+	return out.Bytes(), true
+}
+
+// separator returns what separates the entries either side of a removed
+// entry: the blank lines and comments that followed it, or failing that, those
+// that preceded it.
+func (r *documentRenderer) separator(pending []byte, removed sourceEntry) []byte {
+	if trailing := r.linesBetween(removed.trailing, removed.end); len(trailing) > 0 {
+		return trailing
+	}
+	return pending
+}
+
+// sourceEntries finds the lines each entry of the original collection was
+// parsed from.
+func (r *documentRenderer) sourceEntries(original *yaml.Node, width, column, from, to int) ([]sourceEntry, bool) {
+	var entries []sourceEntry
+	for i := 0; i+width <= len(original.Content); i += width {
+		first := original.Content[i]
+		line := first.Line - 1
+		if width == 1 {
+			// an item's position is that of its content, which can follow
+			// the dash on a later line
+			for line > from && !r.hasDashAt(line, column) {
+				line--
+			}
+		}
+		start := line
+		for start > from && r.isCommentAt(start-1, column) {
+			start--
+		}
+		if start < from || line >= to || (len(entries) > 0 && start <= entries[len(entries)-1].start) {
+			return nil, false
+		}
+		entries = append(entries, sourceEntry{nodes: original.Content[i : i+width], start: start})
+	}
+
+	for i := range entries {
+		end := to
+		if i+1 < len(entries) {
+			end = entries[i+1].start
+		}
+		entries[i].end = end
+		trailing := end
+		for trailing > entries[i].start+1 && r.isSeparator(trailing-1, column) {
+			trailing--
+		}
+		entries[i].trailing = trailing
+	}
+	return entries, true
+}
+
+// renderEntry renders an entry that was in the original collection.
+func (r *documentRenderer) renderEntry(kind yaml.Kind, nodes []*yaml.Node, entry sourceEntry, column int) ([]byte, bool) {
+	unchanged := true
+	for i, node := range nodes {
+		unchanged = unchanged && node == entry.nodes[i] && r.isUnchanged(node)
+	}
+	if unchanged {
+		return r.linesBetween(entry.start, entry.trailing), true
+	}
+
+	// keep the text up to a changed block collection, and render its entries
+	value := nodes[len(nodes)-1]
+	keyUnchanged := kind == yaml.SequenceNode || r.isUnchanged(nodes[0])
+	if original, ok := r.original[value]; ok && keyUnchanged && value == entry.nodes[len(nodes)-1] && isBlock(&original) && len(original.Content) > 0 {
+		valueWidth := 1
+		if original.Kind == yaml.MappingNode {
+			valueWidth = 2
+		}
+		if valueEntries, ok := r.sourceEntries(&original, valueWidth, original.Column-1, entry.start, entry.trailing); ok {
+			if rendered, ok := r.renderBlock(value, valueEntries[0].start, entry.trailing); ok {
+				return append(r.linesBetween(entry.start, valueEntries[0].start), rendered...), true
+			}
+		}
+	}
+
+	if !r.ownsLine(entry, column) {
+		return nil, false
+	}
+	// the comments above the entry are kept as they were, unless they changed
+	first, firstOriginal := nodes[0], r.original[entry.nodes[0]]
+	keepHead := first == entry.nodes[0] && first.HeadComment == firstOriginal.HeadComment
+	out := []byte{}
+	if keepHead {
+		out = r.linesBetween(entry.start, r.firstLine(entry, column))
+	}
+	rendered, ok := r.encodeEntry(kind, nodes, column, !keepHead)
+	if !ok {
+		return nil, false
+	}
+	return append(out, rendered...), true
+}
+
+// encodeEntry encodes the entry as a collection of its own, indented to the
+// given column.
+func (r *documentRenderer) encodeEntry(kind yaml.Kind, nodes []*yaml.Node, column int, withHead bool) ([]byte, bool) {
+	fragment := &yaml.Node{Kind: kind, Content: slices.Clone(nodes)}
+	if !withHead {
+		first := *nodes[0]
+		first.HeadComment = ""
+		fragment.Content[0] = &first
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(r.indent)
+	if err := enc.Encode(fragment); err != nil {
+		return nil, false
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false
+	}
+
+	out := &bytes.Buffer{}
+	prefix := bytes.Repeat([]byte(" "), column)
+	for _, line := range bytes.SplitAfter(buf.Bytes(), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			out.Write(prefix)
+		}
+		out.Write(line)
+	}
+	return r.lineBreaks(out.Bytes()), true
+}
+
+// firstLine returns the line of the entry's key or dash.
+func (r *documentRenderer) firstLine(entry sourceEntry, column int) int {
+	line := entry.start
+	for line < entry.end && r.isCommentAt(line, column) {
+		line++
+	}
+	return line
+}
+
+// ownsLine reports whether nothing but the entry is on its first line, so it
+// can be replaced or removed line by line. The first key of a mapping that is
+// an item of a sequence shares its line with the dash.
+func (r *documentRenderer) ownsLine(entry sourceEntry, column int) bool {
+	line := r.lines[r.firstLine(entry, column)]
+	return column <= len(line) && len(bytes.TrimSpace(line[:column])) == 0
+}
+
+func (r *documentRenderer) hasDashAt(line, column int) bool {
+	text := r.lines[line]
+	return column < len(text) && text[column] == '-' && len(bytes.TrimSpace(text[:column])) == 0
+}
+
+// isCommentAt reports whether the line is a comment indented to the column.
+func (r *documentRenderer) isCommentAt(line, column int) bool {
+	text := r.lines[line]
+	trimmed := bytes.TrimLeft(text, " ")
+	return len(text)-len(trimmed) == column && bytes.HasPrefix(trimmed, []byte("#"))
+}
+
+// isSeparator reports whether the line is blank, the end of the document, or
+// a comment indented less than the column.
+func (r *documentRenderer) isSeparator(line, column int) bool {
+	text := r.lines[line]
+	trimmed := bytes.TrimLeft(text, " ")
+	if len(bytes.TrimSpace(trimmed)) == 0 || bytes.Equal(bytes.TrimRight(text, " \t\r\n"), []byte("...")) {
+		return true
+	}
+	return len(text)-len(trimmed) < column && bytes.HasPrefix(trimmed, []byte("#"))
+}
+
+func (r *documentRenderer) linesBetween(from, to int) []byte {
+	return bytes.Join(r.lines[from:to], nil)
+}
+
+func (r *documentRenderer) copyLines(out *bytes.Buffer, from, to int) {
+	for _, line := range r.lines[from:to] {
+		out.Write(line)
+	}
+}
@@ -0,0 +1,138 @@
+package overlay_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDocument_MinimalDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec     string
+		overlay  string
+		expected string
+	}{
+		// no-op overlays must leave the source byte for byte as it was
+		{spec: "styled.yaml", overlay: "noop.yaml", expected: "styled.yaml"},
+		{spec: "styled.json", overlay: "noop.yaml", expected: "styled.json"},
+		{spec: "../openapi.yaml", overlay: "noop-openapi.yaml", expected: "../openapi.yaml"},
+		{spec: "styled.yaml", overlay: "changes.yaml", expected: "styled-changed.yaml"},
+		{spec: "emptied.yaml", overlay: "remove-entries.yaml", expected: "emptied-changed.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+"+"+tt.overlay, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := os.ReadFile("testdata/minimal-diff/" + tt.spec)
+			require.NoError(t, err)
+			doc, err := overlay.ParseDocument(data)
+			require.NoError(t, err)
+			o, err := loader.LoadOverlay("testdata/minimal-diff/" + tt.overlay)
+			require.NoError(t, err)
+
+			_, err = o.ApplyToContext(context.Background(), doc.Root, &overlay.ApplyOptions{MinimalDiff: true})
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			require.NoError(t, doc.Encode(&out))
+			expected, err := os.ReadFile("testdata/minimal-diff/" + tt.expected)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), out.String())
+		})
+	}
+}
+
+func TestDocument_MinimalDiffCRLF(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/minimal-diff/styled.yaml")
+	require.NoError(t, err)
+	doc, err := overlay.ParseDocument(bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n")))
+	require.NoError(t, err)
+	o, err := loader.LoadOverlay("testdata/minimal-diff/changes.yaml")
+	require.NoError(t, err)
+
+	_, err = o.ApplyToContext(context.Background(), doc.Root, &overlay.ApplyOptions{MinimalDiff: true})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, doc.Encode(&out))
+	expected, err := os.ReadFile("testdata/minimal-diff/styled-changed.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll(string(expected), "\n", "\r\n"), out.String())
+}
+
+func TestDocument_Encode(t *testing.T) {
+	t.Parallel()
+
+	doc, err := overlay.ParseDocument([]byte("a: 1\nb:\n  - x\n  - y"))
+	require.NoError(t, err)
+	doc.Root.Content[0].Content[3].Content[1].Value = "z"
+	var out bytes.Buffer
+	require.NoError(t, doc.Encode(&out))
+	assert.Equal(t, "a: 1\nb:\n  - x\n  - z\n", out.String(), "changes should be re-encoded even without a final line break")
+
+	*doc.Root.Content[0] = yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "first"}}}
+	out.Reset()
+	require.NoError(t, doc.Encode(&out))
+	assert.Equal(t, "- first\n", out.String(), "a document whose root changed kind should be encoded as a whole")
+}
+
+const multiDocumentSpec = `# the first document
+openapi: 3.1.0
+info: {title: Drinks, version: 1.0.0}
+---
+# the second document
+openapi: 3.1.0
+info:
+  title:   Orders
+  version: 1.0.0
+...
+`
+
+func TestParseDocuments(t *testing.T) {
+	t.Parallel()
+
+	docs, err := overlay.ParseDocuments([]byte(multiDocumentSpec))
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	_, err = overlay.ParseDocument([]byte(multiDocumentSpec))
+	assert.ErrorContains(t, err, "expected a single document, but found more than one")
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-document: 1
+info: {title: Second, version: 1.0.0}
+actions:
+  - target: $.info
+    update: {version: 2.0.0}
+`), &o))
+	index, err := o.SelectDocument(len(docs))
+	require.NoError(t, err)
+	assert.Equal(t, 1, index)
+	require.NoError(t, o.ApplyTo(docs[index].Root))
+
+	var out bytes.Buffer
+	for _, doc := range docs {
+		require.NoError(t, doc.Encode(&out))
+	}
+	assert.Equal(t, strings.Replace(multiDocumentSpec, "version: 1.0.0\n...", "version: 2.0.0\n...", 1), out.String())
+
+	_, err = o.SelectDocument(1)
+	assert.ErrorContains(t, err, "x-speakeasy-document is 1, but the specification has 1 documents")
+	o.Document = nil
+	_, err = o.SelectDocument(2)
+	assert.ErrorContains(t, err, "the specification has 2 documents")
+}
@@ -0,0 +1,369 @@
+package overlay
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportOptions control how patches are turned into overlays.
+type ImportOptions struct {
+	// Title is the title of the overlay.
+	Title string
+
+	// Spec is the specification the patch applies to. It is left unchanged.
+	// Without it, a numeric token of a JSON pointer is taken as an array
+	// index, operations that need the value of a node, such as copy, are
+	// skipped, and replacing a node may merge the new value into it instead.
+	Spec *yaml.Node
+}
+
+// UnmarshalYAML keeps a null value, which would otherwise be left nil.
+func (op *JSONPatchOperation) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Op    string    `yaml:"op"`
+		Path  string    `yaml:"path"`
+		From  string    `yaml:"from"`
+		Value yaml.Node `yaml:"value"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*op = JSONPatchOperation{Op: raw.Op, Path: raw.Path, From: raw.From}
+	if raw.Value.Kind != 0 {
+		op.Value = &raw.Value
+	}
+	return nil
+}
+
+// FromJSONPatch converts a JSON Patch (RFC 6902) into an overlay. The add,
+// remove, replace, move and copy operations become actions with normalized
+// JSONPath targets. Operations with no overlay equivalent, such as test or
+// inserting into the middle of an array, are skipped with a warning.
+func FromJSONPatch(patch JSONPatch, opts *ImportOptions) (*Overlay, []string, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	im := &patchImporter{}
+	if opts.Spec != nil {
+		im.doc = clone(opts.Spec)
+		if im.doc.Kind == yaml.DocumentNode && len(im.doc.Content) > 0 {
+			im.doc = im.doc.Content[0]
+		}
+	}
+	for i, op := range patch {
+		im.op = i
+		if err := im.importOperation(op); err != nil {
+			return nil, im.warnings, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return newImportedOverlay(opts.Title, im.actions), im.warnings, nil
+}
+
+// FromMergePatch converts a JSON Merge Patch (RFC 7396) into an overlay. Each
+// null in the patch becomes a remove action, and the rest a single update of
+// the whole document. Arrays are cleared before they are updated, as a merge
+// patch replaces them.
+func FromMergePatch(patch MergePatch, opts *ImportOptions) (*Overlay, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	node := patch.Node
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("a merge patch that is not an object replaces the whole document, which has no overlay equivalent")
+	}
+
+	var removes, clears []Action
+	var walk func(path simplePath, patch *yaml.Node) *yaml.Node
+	walk = func(path simplePath, patch *yaml.Node) *yaml.Node {
+		update := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i+1 < len(patch.Content); i += 2 {
+			key, value := patch.Content[i], patch.Content[i+1]
+			childPath := path.WithKey(key.Value)
+			switch {
+			case isNull(value):
+				removes = append(removes, Action{Target: childPath.ToJSONPath(), Remove: true})
+				continue
+			case value.Kind == yaml.MappingNode:
+				value = walk(slices.Clone(childPath), value)
+			case value.Kind == yaml.SequenceNode:
+				clears = append(clears, Action{Target: childPath.ToJSONPath() + "[*]", Remove: true})
+				value = clone(value)
+			default:
+				value = clone(value)
+			}
+			update.Content = append(update.Content, clone(key), value)
+		}
+		return update
+	}
+	update := walk(simplePath{}, node)
+
+	actions := append(removes, clears...)
+	if len(update.Content) > 0 {
+		actions = append(actions, Action{Target: "$", Update: *update})
+	}
+	return newImportedOverlay(opts.Title, actions), nil
+}
+
+func newImportedOverlay(title string, actions []Action) *Overlay {
+	return &Overlay{
+		Version:         "1.0.0",
+		JSONPathVersion: "rfc9535",
+		Info: Info{
+			Title:   title,
+			Version: "0.0.0",
+		},
+		Actions: actions,
+	}
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// patchImporter turns the operations of a JSON Patch into actions, applying
+// them to a copy of the specification, if there is one, to tell what each
+// pointer refers to.
+type patchImporter struct {
+	doc      *yaml.Node
+	op       int
+	actions  []Action
+	warnings []string
+}
+
+// patchLocation is what a JSON pointer refers to.
+type patchLocation struct {
+	path simplePath
+
+	// parent and node are the nodes of the copy of the specification, if there
+	// is one. node is nil if the pointer is to a new mapping key or to the end
+	// of an array, in which case path is the path of the parent.
+	parent *yaml.Node
+	node   *yaml.Node
+
+	// key is the last token of the pointer, and end reports whether it refers
+	// to the end of an array. root reports whether the pointer is empty.
+	key  string
+	end  bool
+	root bool
+
+	// index reports whether the last token was taken as an array index.
+	index bool
+}
+
+func (im *patchImporter) warn(format string, args ...any) {
+	im.warnings = append(im.warnings, fmt.Sprintf("operation %d: ", im.op)+fmt.Sprintf(format, args...))
+}
+
+func (im *patchImporter) importOperation(op JSONPatchOperation) error {
+	switch op.Op {
+	case "add", "replace":
+		if op.Value == nil {
+			return fmt.Errorf("%s needs a value", op.Op)
+		}
+		loc, err := im.locate(op.Path, op.Op == "add")
+		if err != nil {
+			return err
+		}
+		if op.Op == "add" {
+			return im.add(loc, op.Value)
+		}
+		return im.replace(loc, op.Value)
+	case "remove":
+		loc, err := im.locate(op.Path, false)
+		if err != nil {
+			return err
+		}
+		return im.remove(loc)
+	case "move", "copy":
+		return im.moveOrCopy(op)
+	case "test":
+		im.warn("test operations have no overlay equivalent and were skipped")
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", op.Op)
+}
+
+func (im *patchImporter) add(loc patchLocation, value *yaml.Node) error {
+	switch {
+	case loc.root:
+		return im.replace(loc, value)
+	case loc.end:
+		im.actions = append(im.actions, Action{
+			Target: loc.path.ToJSONPath(),
+			Update: yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{clone(value)}},
+		})
+		if loc.parent != nil {
+			loc.parent.Content = append(loc.parent.Content, clone(value))
+		}
+	case loc.index:
+		im.warn("inserting into the middle of an array has no overlay equivalent and was skipped")
+	case loc.node != nil:
+		// adding an existing key replaces its value
+		return im.replace(loc, value)
+	default:
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: loc.key}
+		im.actions = append(im.actions, Action{
+			Target: loc.path.ToJSONPath(),
+			Update: yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, clone(value)}},
+		})
+		if loc.parent != nil {
+			loc.parent.Content = append(loc.parent.Content, clone(key), clone(value))
+		}
+	}
+	return nil
+}
+
+func (im *patchImporter) replace(loc patchLocation, value *yaml.Node) error {
+	collection := value.Kind == yaml.MappingNode || value.Kind == yaml.SequenceNode
+	if collection && (im.doc == nil || loc.node.Kind == value.Kind && len(loc.node.Content) > 0) {
+		// an update merges into a collection of the same kind, so empty it first
+		im.actions = append(im.actions, Action{Target: loc.path.ToJSONPath() + "[*]", Remove: true})
+	}
+	im.actions = append(im.actions, Action{Target: loc.path.ToJSONPath(), Update: *clone(value)})
+	if loc.node != nil {
+		*loc.node = *clone(value)
+	}
+	return nil
+}
+
+func (im *patchImporter) remove(loc patchLocation) error {
+	if loc.root {
+		im.warn("removing the whole document has no overlay equivalent and was skipped")
+		return nil
+	}
+	im.actions = append(im.actions, Action{Target: loc.path.ToJSONPath(), Remove: true})
+	if loc.parent != nil {
+		removeChild(loc.parent, loc.node)
+	}
+	return nil
+}
+
+func (im *patchImporter) moveOrCopy(op JSONPatchOperation) error {
+	from, err := im.locate(op.From, false)
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	to, err := im.locate(op.Path, true)
+	if err != nil {
+		return err
+	}
+
+	// a move to another key of the same mapping is a rename, which keeps the
+	// node where it is
+	if op.Op == "move" && !from.root && !from.index && !to.index && !to.end &&
+		slices.Equal(from.path.Dir(), to.path) && (im.doc == nil || to.node == nil) {
+		if from.key == to.key {
+			return nil
+		}
+		im.actions = append(im.actions, Action{
+			Target:     from.path.ToJSONPath(),
+			Extensions: Extensions{RenameExtension: to.key},
+		})
+		if from.parent != nil {
+			for i := 0; i+1 < len(from.parent.Content); i += 2 {
+				if from.parent.Content[i+1] == from.node {
+					from.parent.Content[i].Value = to.key
+				}
+			}
+		}
+		return nil
+	}
+
+	if im.doc == nil {
+		im.warn("%s operations need the specification to be given, and were skipped", op.Op)
+		return nil
+	}
+	value := clone(from.node)
+	if op.Op == "move" {
+		if err := im.remove(from); err != nil {
+			return err
+		}
+		// removing the node can change what the path refers to
+		if to, err = im.locate(op.Path, true); err != nil {
+			return err
+		}
+	}
+	return im.add(to, value)
+}
+
+// locate works out what the JSON pointer refers to. With add, it may refer to
+// a new mapping key or to the end of an array.
+func (im *patchImporter) locate(pointer string, add bool) (patchLocation, error) {
+	var tokens []string
+	if pointer != "" {
+		if !strings.HasPrefix(pointer, "/") {
+			return patchLocation{}, fmt.Errorf("invalid JSON pointer %q", pointer)
+		}
+		for _, token := range strings.Split(pointer[1:], "/") {
+			tokens = append(tokens, strings.NewReplacer("~1", "/", "~0", "~").Replace(token))
+		}
+	}
+
+	loc := patchLocation{path: simplePath{}, node: im.doc, root: len(tokens) == 0}
+	for i, token := range tokens {
+		last := i == len(tokens)-1
+		loc.key, loc.index = token, false
+
+		if im.doc == nil {
+			if token == "-" && last && add {
+				loc.end = true
+			} else if index, err := strconv.Atoi(token); err == nil && index >= 0 {
+				im.warn("%q was taken as an array index, as no specification was given", token)
+				loc.path = loc.path.WithIndex(index)
+				loc.index = true
+			} else {
+				loc.path = loc.path.WithKey(token)
+			}
+			continue
+		}
+
+		node := loc.node
+		loc.parent, loc.node = node, nil
+		switch node.Kind {
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == token {
+					loc.node = node.Content[j+1]
+				}
+			}
+			if loc.node == nil && !(last && add) {
+				return loc, fmt.Errorf("%q not found", pointer)
+			}
+			if loc.node != nil {
+				loc.path = loc.path.WithKey(token)
+			}
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(token)
+			switch {
+			case last && add && (token == "-" || err == nil && index == len(node.Content)):
+				loc.end = true
+			case err != nil || index < 0 || index >= len(node.Content):
+				return loc, fmt.Errorf("%q not found", pointer)
+			default:
+				loc.node = node.Content[index]
+				loc.path = loc.path.WithIndex(index)
+				loc.index = true
+			}
+		default:
+			return loc, fmt.Errorf("%q not found", pointer)
+		}
+	}
+
+	if im.doc == nil && add && !loc.root && !loc.index && !loc.end {
+		// as for a new key when the specification is known, the path is that
+		// of the mapping the key is added to
+		loc.path = loc.path.Dir()
+	}
+	loc.path = slices.Clone(loc.path)
+	return loc, nil
+}
@@ -0,0 +1,147 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFromJSONPatch_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	expected, err := loader.LoadSpecification("testdata/openapi-overlayed.yaml")
+	require.NoError(t, err)
+
+	patch, err := overlay.CompareJSONPatch(node, *expected)
+	require.NoError(t, err)
+	o, warnings, err := overlay.FromJSONPatch(patch, &overlay.ImportOptions{Spec: node})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	require.NoError(t, o.ApplyTo(node))
+	NodeMatchesFile(t, node, "testdata/openapi-overlayed.yaml")
+}
+
+func TestFromJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	var spec yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`
+info:
+  title: Drinks
+  version: 1.0.0
+tags:
+  - name: drinks
+  - name: orders
+components:
+  schemas:
+    Drink:
+      type: object
+`), &spec))
+
+	var patch overlay.JSONPatch
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- {op: test, path: /info/title, value: Drinks}
+- {op: replace, path: /info/title, value: Bar}
+- {op: add, path: /info/summary, value: null}
+- op: add
+  path: /tags/-
+  value:
+    name: ingredients
+- {op: add, path: /tags/0, value: {name: first}}
+- {op: remove, path: /tags/1}
+- {op: move, from: /components/schemas/Drink, path: /components/schemas/Beverage}
+- {op: copy, from: /components/schemas/Beverage, path: /components/schemas/Cocktail}
+`), &patch))
+
+	o, warnings, err := overlay.FromJSONPatch(patch, &overlay.ImportOptions{Title: "Imported", Spec: &spec})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"operation 0: test operations have no overlay equivalent and were skipped",
+		"operation 4: inserting into the middle of an array has no overlay equivalent and was skipped",
+	}, warnings)
+
+	actual, err := o.ToString()
+	require.NoError(t, err)
+	assert.Equal(t, `overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Imported
+  version: 0.0.0
+actions:
+  - target: $["info"]["title"]
+    update: Bar
+  - target: $["info"]
+    update:
+      summary: null
+  - target: $["tags"]
+    update:
+      - name: ingredients
+  - target: $["tags"][1]
+    remove: true
+  - target: $["components"]["schemas"]["Drink"]
+    x-speakeasy-rename: Beverage
+  - target: $["components"]["schemas"]
+    update:
+      Cocktail:
+        type: object
+`, actual)
+
+	require.NoError(t, o.ApplyTo(&spec))
+	var result map[string]any
+	require.NoError(t, spec.Decode(&result))
+	assert.Equal(t, []any{map[string]any{"name": "drinks"}, map[string]any{"name": "ingredients"}}, result["tags"])
+	assert.Equal(t, map[string]any{"title": "Bar", "version": "1.0.0", "summary": nil}, result["info"])
+	assert.Equal(t, map[string]any{"Beverage": map[string]any{"type": "object"}, "Cocktail": map[string]any{"type": "object"}}, result["components"].(map[string]any)["schemas"])
+
+	_, _, err = overlay.FromJSONPatch(overlay.JSONPatch{{Op: "remove", Path: "/missing"}}, &overlay.ImportOptions{Spec: &spec})
+	assert.ErrorContains(t, err, `operation 0 (remove /missing): "/missing" not found`)
+}
+
+func TestFromJSONPatch_WithoutSpec(t *testing.T) {
+	t.Parallel()
+
+	patch := overlay.JSONPatch{
+		{Op: "remove", Path: "/paths/~1drinks/get/responses/200"},
+		{Op: "copy", From: "/a", Path: "/b"},
+		{Op: "move", From: "/a/b", Path: "/a/c"},
+	}
+	o, warnings, err := overlay.FromJSONPatch(patch, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`operation 0: "200" was taken as an array index, as no specification was given`,
+		"operation 1: copy operations need the specification to be given, and were skipped",
+	}, warnings)
+	require.Len(t, o.Actions, 2)
+	assert.Equal(t, `$["paths"]["/drinks"]["get"]["responses"][200]`, o.Actions[0].Target)
+	assert.Equal(t, `$["a"]["b"]`, o.Actions[1].Target)
+	assert.Equal(t, "c", o.Actions[1].Extensions[overlay.RenameExtension])
+}
+
+func TestFromMergePatch(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	expected, err := loader.LoadSpecification("testdata/openapi-overlayed.yaml")
+	require.NoError(t, err)
+
+	patch, err := overlay.CompareMergePatch(node, *expected)
+	require.NoError(t, err)
+	o, err := overlay.FromMergePatch(patch, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, o.ApplyTo(node))
+	NodeMatchesFile(t, node, "testdata/openapi-overlayed.yaml")
+
+	var scalar yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`"replacement"`), &scalar))
+	_, err = overlay.FromMergePatch(overlay.MergePatch{Node: &scalar}, nil)
+	assert.Error(t, err)
+}
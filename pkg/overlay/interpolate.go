@@ -0,0 +1,90 @@
+package overlay
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolate returns a copy of the node with each ${name} in its scalars,
+// keys included, replaced by the value of the variable. $${ stands for a
+// literal ${. The node itself is left unchanged, and is returned as it is when
+// none of its scalars contain ${.
+//
+// A scalar that changes is a string, whatever it looks like, so that its type
+// is the same whether the update adds it or merges it into an existing string.
+func interpolate(node *yaml.Node, vars map[string]string) (*yaml.Node, error) {
+	if !containsPlaceholder(node) {
+		return node, nil
+	}
+
+	result := clone(node)
+	var walk func(node *yaml.Node) error
+	walk = func(node *yaml.Node) error {
+		if node.Kind == yaml.ScalarNode {
+			value, err := interpolateString(node.Value, vars)
+			if err != nil {
+				return err
+			}
+			if value != node.Value {
+				node.Value = value
+				node.Tag = "!!str"
+			}
+		}
+		for _, child := range node.Content {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// containsPlaceholder reports whether any scalar in the node contains ${.
+func containsPlaceholder(node *yaml.Node) bool {
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "${") {
+		return true
+	}
+	for _, child := range node.Content {
+		if containsPlaceholder(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func interpolateString(s string, vars map[string]string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		if start > 0 && s[start-1] == '$' {
+			out.WriteString(s[:start-1] + "${")
+			s = s[start+2:]
+			continue
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		name := s[start+2 : start+end]
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("variable %q is not set", name)
+		}
+		out.WriteString(s[:start] + value)
+		s = s[start+end+1:]
+	}
+}
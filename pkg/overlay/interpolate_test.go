@@ -0,0 +1,124 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const interpolateOverlay = `
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Build values
+  version: 0.0.0
+actions:
+  - target: $.info
+    update:
+      version: ${version}
+      x-build: "${build}"
+      x-port: ${port}
+      contact:
+        email: api+${env}@example.com
+      x-literal: $${version}
+      x-${env}: true
+`
+
+func TestInterpolate(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(interpolateOverlay), &o))
+	before, err := o.ToString()
+	require.NoError(t, err)
+
+	vars := map[string]string{"version": "1.2.3", "build": "42", "port": "8080", "env": "prod"}
+	for range 2 {
+		var node yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar, version: 0.0.0}\n"), &node))
+		_, err = o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{Vars: vars, Interpolate: true})
+		require.NoError(t, err)
+
+		actual, err := yaml.Marshal(&node)
+		require.NoError(t, err)
+		assert.Equal(t, `info: {title: Bar, version: 1.2.3, x-build: "42", x-port: "8080", contact: {email: api+prod@example.com}, x-literal: '${version}', x-prod: true}
+`, string(actual), "substituted values should be strings")
+	}
+
+	after, err := o.ToString()
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "the overlay should be left unchanged")
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+	_, err = o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{Vars: map[string]string{"version": "1.2.3"}, Interpolate: true})
+	assert.EqualError(t, err, `failed to interpolate update for target "$.info": variable "build" is not set`)
+
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+	_, err = o.ApplyToContext(context.Background(), &node, nil)
+	require.NoError(t, err)
+	actual, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Contains(t, string(actual), "version: '${version}'", "interpolation should be opt-in")
+}
+
+func TestInterpolate_EmptyValue(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Empty values
+  version: 0.0.0
+actions:
+  - target: $.info
+    update:
+      version: ${version}
+      x-suffix: v${version}
+`), &o))
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+	_, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{Vars: map[string]string{"version": ""}, Interpolate: true})
+	require.NoError(t, err)
+
+	actual, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Equal(t, "info: {title: Bar, version: \"\", x-suffix: v}\n", string(actual), "an empty value should stay a string")
+}
+
+func TestInterpolate_ExistingKey(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Ports
+  version: 0.0.0
+actions:
+  - target: $.info
+    update:
+      x-port: ${port}
+`), &o))
+
+	for _, spec := range []string{"info: {title: Bar}\n", "info: {title: Bar, x-port: \"80\"}\n"} {
+		var node yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(spec), &node))
+		_, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{Vars: map[string]string{"port": "8080"}, Interpolate: true})
+		require.NoError(t, err)
+
+		var doc struct {
+			Info map[string]any `yaml:"info"`
+		}
+		require.NoError(t, node.Decode(&doc))
+		assert.Equal(t, "8080", doc.Info["x-port"], "the type should not depend on whether the key was there: %s", spec)
+	}
+}
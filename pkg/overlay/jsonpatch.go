@@ -0,0 +1,190 @@
+package overlay
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSONPatch is a JSON Patch (RFC 6902) document.
+type JSONPatch []JSONPatchOperation
+
+// JSONPatchOperation is a single operation of a JSON Patch.
+type JSONPatchOperation struct {
+	Op   string `yaml:"op"`
+	Path string `yaml:"path"`
+	From string `yaml:"from,omitempty"`
+
+	// Value is the value to add, replace or test with, or nil for operations
+	// that take none.
+	Value *yaml.Node `yaml:"value,omitempty"`
+}
+
+// MarshalJSON writes the operation as JSON, keeping the keys of its value in
+// order.
+func (op JSONPatchOperation) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		From  string          `json:"from,omitempty"`
+		Value json.RawMessage `json:"value,omitempty"`
+	}{Op: op.Op, Path: op.Path, From: op.From}
+	if op.Value != nil {
+		value, err := marshalJSONNode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		out.Value = value
+	}
+	return json.Marshal(out)
+}
+
+// MergePatch is a JSON Merge Patch (RFC 7396) document.
+type MergePatch struct {
+	Node *yaml.Node
+}
+
+// MarshalJSON writes the patch as JSON, keeping its keys in order.
+func (p MergePatch) MarshalJSON() ([]byte, error) {
+	return marshalJSONNode(p.Node)
+}
+
+// CompareJSONPatch compares two specifications and returns the JSON Patch
+// that will convert the first into the second.
+func CompareJSONPatch(y1 *yaml.Node, y2 yaml.Node) (JSONPatch, error) {
+	diffs, err := walkTrees(simplePath{}, y1, y2)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := JSONPatch{}
+	for _, diff := range diffs {
+		pointer := strings.TrimPrefix(diff.path.ToJSONPointer(), "#")
+		switch diff.kind {
+		case differenceAdd:
+			patch = append(patch, JSONPatchOperation{Op: "add", Path: pointer, Value: diff.value})
+		case differenceReplace, differenceReplaceItems:
+			patch = append(patch, JSONPatchOperation{Op: "replace", Path: pointer, Value: diff.value})
+		case differenceRemove:
+			patch = append(patch, JSONPatchOperation{Op: "remove", Path: pointer})
+		case differenceAppend:
+			patch = append(patch, JSONPatchOperation{Op: "add", Path: pointer + "/-", Value: diff.value})
+		}
+	}
+	return patch, nil
+}
+
+// CompareMergePatch compares two specifications and returns the JSON Merge
+// Patch that will convert the first into the second.
+//
+// A merge patch cannot change part of a sequence, so any sequence that
+// changed is replaced as a whole. Nor can it set a value to null, as null
+// removes the key instead.
+func CompareMergePatch(y1 *yaml.Node, y2 yaml.Node) (MergePatch, error) {
+	diffs, err := walkTrees(simplePath{}, y1, y2)
+	if err != nil {
+		return MergePatch{}, err
+	}
+
+	patch := &yaml.Node{Kind: yaml.MappingNode}
+	for _, diff := range diffs {
+		path, value := diff.path, diff.value
+		for i, part := range path {
+			if !part.isKey {
+				path = path[:i]
+				break
+			}
+		}
+		switch {
+		case len(path) < len(diff.path) || diff.kind == differenceAppend || diff.kind == differenceReplaceItems:
+			value = path.Resolve(&y2)
+		case diff.kind == differenceRemove:
+			value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+		patch = setMergePatch(patch, path, value)
+	}
+	return MergePatch{Node: patch}, nil
+}
+
+// setMergePatch sets the value at the given path of mapping keys in the
+// patch, returning the patch.
+func setMergePatch(patch *yaml.Node, path simplePath, value *yaml.Node) *yaml.Node {
+	if len(path) == 0 {
+		return value
+	}
+	if patch.Kind != yaml.MappingNode {
+		// an ancestor is already replaced as a whole
+		return patch
+	}
+
+	key := path[0].KeyString()
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		if patch.Content[i].Value == key {
+			patch.Content[i+1] = setMergePatch(patch.Content[i+1], path[1:], value)
+			return patch
+		}
+	}
+	child := value
+	if len(path) > 1 {
+		child = setMergePatch(&yaml.Node{Kind: yaml.MappingNode}, path[1:], value)
+	}
+	patch.Content = append(patch.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+	return patch
+}
+
+// ToJSONPatch returns a JSON Patch that has the same effect on the given
+// specification as the overlay. The specification is left unchanged. As with
+// ApplyTo, actions with an x-when condition are skipped.
+func (o *Overlay) ToJSONPatch(root *yaml.Node) (JSONPatch, error) {
+	result := clone(root)
+	if err := o.ApplyTo(result); err != nil {
+		return nil, err
+	}
+	return CompareJSONPatch(root, *result)
+}
+
+// marshalJSONNode writes the node as JSON, keeping the keys of mappings in
+// order.
+func marshalJSONNode(node *yaml.Node) ([]byte, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return []byte("null"), nil
+		}
+		return marshalJSONNode(node.Content[0])
+	case yaml.AliasNode:
+		return marshalJSONNode(node.Alias)
+	case yaml.MappingNode, yaml.SequenceNode:
+		var buf bytes.Buffer
+		open, close := byte('['), byte(']')
+		if node.Kind == yaml.MappingNode {
+			open, close = '{', '}'
+		}
+		buf.WriteByte(open)
+		for i, child := range node.Content {
+			if i > 0 {
+				if node.Kind == yaml.MappingNode && i%2 == 1 {
+					buf.WriteByte(':')
+				} else {
+					buf.WriteByte(',')
+				}
+			}
+			var data []byte
+			var err error
+			if node.Kind == yaml.MappingNode && i%2 == 0 {
+				data, err = json.Marshal(child.Value)
+			} else {
+				data, err = marshalJSONNode(child)
+			}
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(data)
+		}
+		buf.WriteByte(close)
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(jsonValue(node))
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/speakeasy-api/jsonpath/pkg/jsonpath"
 	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/config"
+	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/token"
 	"github.com/vmware-labs/yaml-jsonpath/pkg/yamlpath"
 	"gopkg.in/yaml.v3"
+	"strconv"
 )
 
 type Queryable interface {
@@ -38,6 +40,20 @@ func (o *Overlay) NewPath(target string, warnings *[]string) (Queryable, error)
 	return mustExecute(path), err
 }
 
+// queryTarget returns the nodes selected by the action's target in the given
+// document.
+func (o *Overlay) queryTarget(root *yaml.Node, action Action) ([]*yaml.Node, error) {
+	if action.Target == "" {
+		return nil, nil
+	}
+
+	p, err := o.NewPath(action.Target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(root), nil
+}
+
 func (o *Overlay) UsesRFC9535() bool {
 	return o.JSONPathVersion == "rfc9535"
 }
@@ -45,3 +61,38 @@ func (o *Overlay) UsesRFC9535() bool {
 func mustExecute(path *yamlpath.Path) yamlPathQueryable {
 	return yamlPathQueryable{path}
 }
+
+// leadingSimplePath returns the longest leading part of the target that only
+// consists of name and index selectors, such as $.paths["/drinks"].get or
+// $["tags"][0]. It also reports whether that covers the whole target.
+func leadingSimplePath(target string) (simplePath, bool) {
+	tokens := token.NewTokenizer(target, config.WithPropertyNameExtension()).Tokenize()
+	if len(tokens) == 0 || tokens[0].Token != token.ROOT {
+		return nil, false
+	}
+
+	path := simplePath{}
+	i := 1
+	for i < len(tokens) {
+		switch {
+		case i+1 < len(tokens) && tokens[i].Token == token.CHILD && tokens[i+1].Token == token.STRING:
+			path = append(path, keyPart(tokens[i+1].Literal))
+			i += 2
+		case i+2 < len(tokens) && tokens[i].Token == token.BRACKET_LEFT && tokens[i+2].Token == token.BRACKET_RIGHT &&
+			tokens[i+1].Token == token.STRING_LITERAL:
+			path = append(path, keyPart(tokens[i+1].Literal))
+			i += 3
+		case i+2 < len(tokens) && tokens[i].Token == token.BRACKET_LEFT && tokens[i+2].Token == token.BRACKET_RIGHT &&
+			tokens[i+1].Token == token.INTEGER:
+			index, err := strconv.Atoi(tokens[i+1].Literal)
+			if err != nil || index < 0 {
+				return path, false
+			}
+			path = append(path, intPart(index))
+			i += 3
+		default:
+			return path, false
+		}
+	}
+	return path, true
+}
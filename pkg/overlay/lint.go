@@ -0,0 +1,212 @@
+package overlay
+
+import (
+	"fmt"
+
+	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/config"
+	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/token"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how seriously a lint finding should be taken.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityOff     Severity = "off"
+)
+
+// LintConfig toggles lint rules and overrides their severities. Rules that are
+// not mentioned keep their default severity.
+type LintConfig struct {
+	Rules map[string]Severity `yaml:"rules" json:"rules"`
+}
+
+// LintRule describes a rule checked by Lint.
+type LintRule struct {
+	ID          string
+	Description string
+	Severity    Severity
+
+	// RequiresSpec marks rules that are only checked when Lint is given a
+	// specification to evaluate the actions against.
+	RequiresSpec bool
+
+	check func(o *Overlay, i int, action Action) []string
+}
+
+// LintFinding is a problem reported by Lint.
+type LintFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+
+	// Action is the index of the action the finding is reported for.
+	Action  int    `json:"action"`
+	Target  string `json:"target"`
+	Message string `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: action at index %d (%s): %s [%s]", f.Severity, f.Action, f.Target, f.Message, f.Rule)
+}
+
+var lintRules = []LintRule{
+	{
+		ID:          "bracket-notation",
+		Description: "targets must use bracket notation rather than dot notation to select names",
+		Severity:    SeverityWarning,
+		check: func(o *Overlay, i int, action Action) []string {
+			tokens := token.NewTokenizer(action.Target, config.WithPropertyNameExtension()).Tokenize()
+			for j := 0; j+1 < len(tokens); j++ {
+				if tokens[j].Token == token.CHILD && tokens[j+1].Token == token.STRING {
+					return []string{fmt.Sprintf("target selects %q with dot notation, use [%q] instead", tokens[j+1].Literal, tokens[j+1].Literal)}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "require-description",
+		Description: "every action must have a description",
+		Severity:    SeverityWarning,
+		check: func(o *Overlay, i int, action Action) []string {
+			if action.Description == "" {
+				return []string{"action has no description"}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "no-recursive-descent",
+		Description: "targets must not use the recursive descent operator ..",
+		Severity:    SeverityWarning,
+		check: func(o *Overlay, i int, action Action) []string {
+			tokens := token.NewTokenizer(action.Target, config.WithPropertyNameExtension()).Tokenize()
+			for _, tok := range tokens {
+				if tok.Token == token.RECURSIVE {
+					return []string{"target uses recursive descent, which may match far more nodes than intended"}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "filter-requires-rfc9535",
+		Description: "targets with filter expressions require the overlay to opt into rfc9535 jsonpath",
+		Severity:    SeverityError,
+		check: func(o *Overlay, i int, action Action) []string {
+			if !o.UsesRFC9535() && targetHasFilterExpression(action.Target) {
+				return []string{"target has a filter expression but the overlay lacks `x-speakeasy-jsonpath: rfc9535`"}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "no-root-update",
+		Description: "update actions must not target the document root",
+		Severity:    SeverityError,
+		check: func(o *Overlay, i int, action Action) []string {
+			path, complete := leadingSimplePath(action.Target)
+			if !action.Remove && complete && len(path) == 0 {
+				return []string{"action updates the whole document"}
+			}
+			return nil
+		},
+	},
+	{
+		ID:           string(ConflictOverwrite),
+		Description:  "actions must not overwrite values written by earlier actions",
+		Severity:     SeverityWarning,
+		RequiresSpec: true,
+	},
+	{
+		ID:           string(ConflictDeadAction),
+		Description:  "actions must match at least one node when applied",
+		Severity:     SeverityWarning,
+		RequiresSpec: true,
+	},
+	{
+		ID:           string(ConflictUndo),
+		Description:  "actions must not undo the changes of earlier actions",
+		Severity:     SeverityWarning,
+		RequiresSpec: true,
+	},
+	{
+		ID:           string(ConflictOrderDependent),
+		Description:  "actions must match the same nodes regardless of the actions applied before them",
+		Severity:     SeverityWarning,
+		RequiresSpec: true,
+	},
+}
+
+// LintRules returns the rules checked by Lint with their default severities.
+func LintRules() []LintRule {
+	return append([]LintRule(nil), lintRules...)
+}
+
+// Lint checks the overlay against the lint rules, with severities taken from
+// the given configuration, which may be nil to use the defaults. When a
+// specification is given, the conflicts reported by DetectConflicts are
+// included as findings of the rule named after their kind.
+func (o *Overlay) Lint(cfg *LintConfig, root *yaml.Node) ([]LintFinding, error) {
+	severities := map[string]Severity{}
+	for _, rule := range lintRules {
+		severities[rule.ID] = rule.Severity
+	}
+	if cfg != nil {
+		for id, severity := range cfg.Rules {
+			if _, ok := severities[id]; !ok {
+				return nil, fmt.Errorf("unknown lint rule %q", id)
+			}
+			switch severity {
+			case SeverityError, SeverityWarning, SeverityOff:
+			default:
+				return nil, fmt.Errorf("lint rule %q has invalid severity %q: expected error, warning or off", id, severity)
+			}
+			severities[id] = severity
+		}
+	}
+
+	var findings []LintFinding
+	for i, action := range o.Actions {
+		for _, rule := range lintRules {
+			if rule.check == nil || severities[rule.ID] == SeverityOff {
+				continue
+			}
+			for _, message := range rule.check(o, i, action) {
+				findings = append(findings, LintFinding{
+					Rule:     rule.ID,
+					Severity: severities[rule.ID],
+					Action:   i,
+					Target:   action.Target,
+					Message:  message,
+				})
+			}
+		}
+	}
+
+	if root == nil {
+		return findings, nil
+	}
+
+	conflicts, err := o.DetectConflicts(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, conflict := range conflicts {
+		severity := severities[string(conflict.Kind)]
+		if severity == SeverityOff {
+			continue
+		}
+		findings = append(findings, LintFinding{
+			Rule:     string(conflict.Kind),
+			Severity: severity,
+			Action:   conflict.Action,
+			Target:   o.Actions[conflict.Action].Target,
+			Message:  conflict.Message,
+		})
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,153 @@
+package overlay_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	o := &overlay.Overlay{
+		Version: "1.0.0",
+		Info:    overlay.Info{Title: "Lint", Version: "1.0.0"},
+		Actions: []overlay.Action{
+			{Target: `$["info"]["title"]`, Description: "fine", Update: yaml.Node{Kind: yaml.ScalarNode, Value: "x"}},
+			{Target: `$.info`, Remove: true},
+			{Target: `$..description`, Description: "recursive", Remove: true},
+			{Target: `$["tags"][?(@["name"] == "drinks")]`, Description: "filter", Remove: true},
+			{Target: `$`, Description: "root", Update: yaml.Node{Kind: yaml.MappingNode}},
+		},
+	}
+
+	type summary struct {
+		Rule     string
+		Severity overlay.Severity
+		Action   int
+	}
+	summarise := func(findings []overlay.LintFinding) []summary {
+		var out []summary
+		for _, f := range findings {
+			out = append(out, summary{f.Rule, f.Severity, f.Action})
+		}
+		return out
+	}
+
+	findings, err := o.Lint(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []summary{
+		{"bracket-notation", overlay.SeverityWarning, 1},
+		{"require-description", overlay.SeverityWarning, 1},
+		{"no-recursive-descent", overlay.SeverityWarning, 2},
+		{"filter-requires-rfc9535", overlay.SeverityError, 3},
+		{"no-root-update", overlay.SeverityError, 4},
+	}, summarise(findings))
+
+	findings, err = o.Lint(&overlay.LintConfig{Rules: map[string]overlay.Severity{
+		"bracket-notation":        overlay.SeverityOff,
+		"require-description":     overlay.SeverityError,
+		"filter-requires-rfc9535": overlay.SeverityOff,
+	}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []summary{
+		{"require-description", overlay.SeverityError, 1},
+		{"no-recursive-descent", overlay.SeverityWarning, 2},
+		{"no-root-update", overlay.SeverityError, 4},
+	}, summarise(findings))
+
+	_, err = o.Lint(&overlay.LintConfig{Rules: map[string]overlay.Severity{"made-up": overlay.SeverityOff}}, nil)
+	assert.ErrorContains(t, err, `unknown lint rule "made-up"`)
+
+	_, err = o.Lint(&overlay.LintConfig{Rules: map[string]overlay.Severity{"bracket-notation": "fatal"}}, nil)
+	assert.ErrorContains(t, err, `invalid severity "fatal"`)
+}
+
+func TestLint_Conflicts(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay-conflicts.yaml")
+	require.NoError(t, err)
+
+	findings, err := o.Lint(&overlay.LintConfig{Rules: map[string]overlay.Severity{
+		"bracket-notation": overlay.SeverityOff,
+		"undo":             overlay.SeverityError,
+		"overwrite":        overlay.SeverityOff,
+	}}, node)
+	require.NoError(t, err)
+
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule)
+		if f.Rule == "undo" {
+			assert.Equal(t, overlay.SeverityError, f.Severity)
+		}
+	}
+	assert.Equal(t, []string{"undo", "undo", "dead-action", "dead-action", "order-dependent"}, rules)
+}
+
+func TestLint_When(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(serversOverlay), &o))
+
+	findings, err := o.Lint(&overlay.LintConfig{Rules: map[string]overlay.Severity{
+		"bracket-notation":    overlay.SeverityOff,
+		"require-description": overlay.SeverityOff,
+	}}, node)
+	require.NoError(t, err)
+
+	// the servers are only set for one environment or the other, but the
+	// title is written twice either way
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, fmt.Sprintf("%s %d", f.Rule, f.Action))
+	}
+	assert.Equal(t, []string{"overwrite 3"}, rules)
+}
+
+func TestLint_OnNoMatchIgnore(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Optional actions
+  version: 0.0.0
+actions:
+  - target: $["paths"]["/v2/drinks"]
+    description: Only there in v2
+    x-on-no-match: ignore
+    remove: true
+  - target: $["paths"]["/v3/drinks"]
+    description: Expected to be there
+    remove: true
+`), &o))
+
+	findings, err := o.Lint(nil, node)
+	require.NoError(t, err)
+
+	// the first action is optional by design
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, fmt.Sprintf("%s %d", f.Rule, f.Action))
+	}
+	assert.Equal(t, []string{"dead-action 1"}, rules)
+}
@@ -0,0 +1,138 @@
+package overlay
+
+import "fmt"
+
+// OnNoMatchExtension is the action field that decides what happens when the
+// action's target matches nothing:
+//
+//	actions:
+//	  - target: $.paths["/v2/drinks"]
+//	    x-on-no-match: ignore
+//	    remove: true
+//
+// Without it, ApplyToStrict fails and the other ways of applying an overlay
+// carry on. A policy that is set applies whether or not the overlay is applied
+// strictly.
+const OnNoMatchExtension = "x-on-no-match"
+
+// ExpectMatchesExtension is the action field that asserts how many nodes the
+// action's target matches, either exactly or within a range:
+//
+//	actions:
+//	  - target: $.components.schemas.Drink
+//	    x-expect-matches: 1
+//	    update: {}
+//	  - target: $.paths.*.get
+//	    x-expect-matches: {min: 1, max: 10}
+//	    update: {}
+//
+// Applying the overlay fails if the assertion does not hold, strictly or not.
+// If the action also sets x-on-no-match, that decides what happens when the
+// target matches nothing, so an optional action can still be limited to a
+// single node.
+const ExpectMatchesExtension = "x-expect-matches"
+
+// NoMatchPolicy decides what happens when an action's target matches nothing.
+type NoMatchPolicy string
+
+const (
+	// NoMatchError fails the apply. It is the default in strict mode.
+	NoMatchError NoMatchPolicy = "error"
+	// NoMatchWarn adds a warning to the result.
+	NoMatchWarn NoMatchPolicy = "warn"
+	// NoMatchIgnore carries on. It is the default outside strict mode.
+	NoMatchIgnore NoMatchPolicy = "ignore"
+)
+
+// matchRange is the number of matches allowed by x-expect-matches. max is -1
+// if there is no upper bound.
+type matchRange struct {
+	min, max int
+}
+
+func (r matchRange) contains(n int) bool {
+	return n >= r.min && (r.max < 0 || n <= r.max)
+}
+
+func (r matchRange) String() string {
+	switch {
+	case r.min == r.max:
+		return fmt.Sprintf("exactly %d", r.min)
+	case r.max < 0:
+		return fmt.Sprintf("at least %d", r.min)
+	case r.min == 0:
+		return fmt.Sprintf("at most %d", r.max)
+	default:
+		return fmt.Sprintf("between %d and %d", r.min, r.max)
+	}
+}
+
+// actionNoMatchPolicy returns the action's x-on-no-match policy, or "" if it
+// has none.
+func actionNoMatchPolicy(action Action) (NoMatchPolicy, error) {
+	value, ok := action.Extensions[OnNoMatchExtension]
+	if !ok {
+		return "", nil
+	}
+	switch policy := NoMatchPolicy(fmt.Sprint(value)); policy {
+	case NoMatchError, NoMatchWarn, NoMatchIgnore:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown policy %q, expected error, warn or ignore", value)
+	}
+}
+
+// actionExpectedMatches returns the action's x-expect-matches range, or nil if
+// it has none.
+func actionExpectedMatches(action Action) (*matchRange, error) {
+	value, ok := action.Extensions[ExpectMatchesExtension]
+	if !ok {
+		return nil, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return nil, fmt.Errorf("expected a count of at least 0, got %d", v)
+		}
+		return &matchRange{min: v, max: v}, nil
+	case map[string]any:
+		r := &matchRange{max: -1}
+		if len(v) == 0 {
+			return nil, fmt.Errorf("expected min, max or both")
+		}
+		for key, bound := range v {
+			n, ok := bound.(int)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("%s must be a count of at least 0", key)
+			}
+			switch key {
+			case "min":
+				r.min = n
+			case "max":
+				r.max = n
+			default:
+				return nil, fmt.Errorf("unknown field %q, expected min or max", key)
+			}
+		}
+		if r.max >= 0 && r.min > r.max {
+			return nil, fmt.Errorf("min %d is greater than max %d", r.min, r.max)
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("expected a count, or a mapping with min and max")
+	}
+}
+
+// validateMatchExtensions checks the action's x-on-no-match and
+// x-expect-matches fields.
+func validateMatchExtensions(i int, action Action) []error {
+	var errs []error
+	if _, err := actionNoMatchPolicy(action); err != nil {
+		errs = append(errs, fmt.Errorf("overlay action at index %d %s is invalid: %w", i, OnNoMatchExtension, err))
+	}
+	if _, err := actionExpectedMatches(action); err != nil {
+		errs = append(errs, fmt.Errorf("overlay action at index %d %s is invalid: %w", i, ExpectMatchesExtension, err))
+	}
+	return errs
+}
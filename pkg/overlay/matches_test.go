@@ -0,0 +1,127 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func applyMatchesOverlay(t *testing.T, actions string, strict bool) (*overlay.ApplyResult, error) {
+	t.Helper()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Matches
+  version: 0.0.0
+actions:
+`+actions), &o))
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("paths:\n  /drinks: {get: {}, post: {}}\n  /orders: {get: {}}\n"), &node))
+	return o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{Strict: strict})
+}
+
+func TestOnNoMatch(t *testing.T) {
+	t.Parallel()
+
+	for _, strict := range []bool{true, false} {
+		result, err := applyMatchesOverlay(t, `
+  - target: $.paths["/v2/drinks"]
+    x-on-no-match: ignore
+    remove: true
+`, strict)
+		require.NoError(t, err)
+		assert.Empty(t, result.Warnings)
+
+		result, err = applyMatchesOverlay(t, `
+  - target: $.paths["/v2/drinks"]
+    x-on-no-match: warn
+    remove: true
+`, strict)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`update action (1 / 1) target=$.paths["/v2/drinks"]: selector "$.paths[\"/v2/drinks\"]" did not match any targets`}, result.Warnings)
+
+		_, err = applyMatchesOverlay(t, `
+  - target: $.paths["/v2/drinks"]
+    x-on-no-match: error
+    remove: true
+`, strict)
+		assert.ErrorContains(t, err, `selector "$.paths[\"/v2/drinks\"]" did not match any targets`, "an explicit policy should apply strictly or not")
+	}
+
+	_, err := applyMatchesOverlay(t, `
+  - target: $.paths["/v2/drinks"]
+    remove: true
+`, false)
+	assert.NoError(t, err, "without a policy, only strict mode should fail")
+}
+
+func TestExpectMatches(t *testing.T) {
+	t.Parallel()
+
+	for _, strict := range []bool{true, false} {
+		_, err := applyMatchesOverlay(t, `
+  - target: $.paths.*.get
+    x-expect-matches: 2
+    update: {x-get: true}
+  - target: $.paths.*.post
+    x-expect-matches: {min: 1, max: 3}
+    update: {x-post: true}
+`, strict)
+		assert.NoError(t, err)
+
+		_, err = applyMatchesOverlay(t, `
+  - target: $.paths.*.get
+    x-expect-matches: 1
+    update: {x-get: true}
+`, strict)
+		assert.ErrorContains(t, err, `selector "$.paths.*.get" matched 2 targets, expected exactly 1`)
+
+		_, err = applyMatchesOverlay(t, `
+  - target: $.paths.*.delete
+    x-expect-matches: {min: 1}
+    update: {x-delete: true}
+`, strict)
+		assert.ErrorContains(t, err, `selector "$.paths.*.delete" matched 0 targets, expected at least 1`)
+
+		_, err = applyMatchesOverlay(t, `
+  - target: $.paths.*.delete
+    x-on-no-match: ignore
+    x-expect-matches: 1
+    update: {x-delete: true}
+`, strict)
+		assert.NoError(t, err, "x-on-no-match should decide when nothing matches")
+	}
+}
+
+func TestMatchExtensions_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, field := range []string{
+		`x-on-no-match: sometimes`,
+		`x-expect-matches: -1`,
+		`x-expect-matches: many`,
+		`x-expect-matches: {min: 3, max: 1}`,
+		`x-expect-matches: {exactly: 1}`,
+	} {
+		var o overlay.Overlay
+		require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+info: {title: Invalid, version: 0.0.0}
+actions:
+  - target: $.paths
+    `+field+`
+    update: {}
+`), &o))
+		assert.ErrorContains(t, o.Validate(), "overlay action at index 0 x-", field)
+		_, err := o.Compile()
+		assert.ErrorContains(t, err, "is invalid", field)
+	}
+}
@@ -0,0 +1,133 @@
+package overlay
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/speakeasy-api/jsonpath/pkg/jsonpath"
+	"github.com/speakeasy-api/jsonpath/pkg/jsonpath/config"
+)
+
+// SourceExtension is the action extension used to record which overlay an
+// action was merged from.
+const SourceExtension = "x-source"
+
+// MergeSource is an overlay to merge along with the name it should be recorded
+// under, usually the file it was loaded from.
+type MergeSource struct {
+	Name    string
+	Overlay *Overlay
+}
+
+// Merge combines the given overlays into a single overlay whose actions are
+// the concatenation of their actions, in order. Each action records the source
+// it came from in its x-source extension, unless it already carries one.
+//
+// The info titles of the sources are joined, and their versions are kept only
+// if they all agree. Every source that sets extends must point at the same
+// document, and top-level extensions must not conflict. When some sources use
+// RFC 9535 JSONPath and others don't, the legacy targets are upgraded as long
+// as they parse as RFC 9535 and don't use filter expressions, whose meaning
+// differs between the two implementations.
+func Merge(sources ...MergeSource) (*Overlay, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("at least one overlay is required to merge")
+	}
+
+	merged := &Overlay{
+		Version: "1.0.0",
+		Info: Info{
+			Version: sources[0].Overlay.Info.Version,
+		},
+		JSONPathVersion: sources[0].Overlay.JSONPathVersion,
+	}
+
+	var titles []string
+	seenTitles := map[string]struct{}{}
+	for _, source := range sources {
+		o := source.Overlay
+
+		if _, seen := seenTitles[o.Info.Title]; !seen && o.Info.Title != "" {
+			seenTitles[o.Info.Title] = struct{}{}
+			titles = append(titles, o.Info.Title)
+		}
+		if o.Info.Version != merged.Info.Version {
+			merged.Info.Version = "0.0.0"
+		}
+
+		if err := mergeExtensions(&merged.Info.Extensions, o.Info.Extensions); err != nil {
+			return nil, fmt.Errorf("failed to merge info of %q: %w", source.Name, err)
+		}
+		if err := mergeExtensions(&merged.Extensions, o.Extensions); err != nil {
+			return nil, fmt.Errorf("failed to merge %q: %w", source.Name, err)
+		}
+
+		if o.Extends != "" {
+			if merged.Extends != "" && merged.Extends != o.Extends {
+				return nil, fmt.Errorf("overlay %q extends %q, but previous overlays extend %q", source.Name, o.Extends, merged.Extends)
+			}
+			merged.Extends = o.Extends
+		}
+
+		if o.Document != nil {
+			if merged.Document != nil && *merged.Document != *o.Document {
+				return nil, fmt.Errorf("overlay %q applies to document %d, but previous overlays apply to document %d", source.Name, *o.Document, *merged.Document)
+			}
+			merged.Document = o.Document
+		}
+
+		if o.JSONPathVersion != merged.JSONPathVersion {
+			merged.JSONPathVersion = "rfc9535"
+		}
+	}
+	merged.Info.Title = strings.Join(titles, " + ")
+
+	for _, source := range sources {
+		o := source.Overlay
+		for i, action := range o.Actions {
+			if merged.UsesRFC9535() && !o.UsesRFC9535() {
+				if err := checkUpgradableTarget(action.Target); err != nil {
+					return nil, fmt.Errorf("overlay %q action at index %d cannot be upgraded to rfc9535 jsonpath: %w", source.Name, i, err)
+				}
+			}
+
+			extensions := make(Extensions, len(action.Extensions)+1)
+			for k, v := range action.Extensions {
+				extensions[k] = v
+			}
+			if _, ok := extensions[SourceExtension]; !ok {
+				extensions[SourceExtension] = fmt.Sprintf("%s#/actions/%d", source.Name, i)
+			}
+			action.Extensions = extensions
+
+			merged.Actions = append(merged.Actions, action)
+		}
+	}
+
+	return merged, nil
+}
+
+func mergeExtensions(dst *Extensions, src Extensions) error {
+	for k, v := range src {
+		if *dst == nil {
+			*dst = Extensions{}
+		}
+		if existing, ok := (*dst)[k]; ok && !reflect.DeepEqual(existing, v) {
+			return fmt.Errorf("conflicting values for extension %q", k)
+		}
+		(*dst)[k] = v
+	}
+	return nil
+}
+
+func checkUpgradableTarget(target string) error {
+	if target == "" {
+		return nil
+	}
+	if targetHasFilterExpression(target) {
+		return fmt.Errorf("target %q uses a filter expression", target)
+	}
+	_, err := jsonpath.NewPath(target, config.WithPropertyNameExtension())
+	return err
+}
@@ -0,0 +1,90 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	o1, err := loader.LoadOverlay("testdata/overlay.yaml")
+	require.NoError(t, err)
+	o2, err := loader.LoadOverlay("testdata/overlay-mismatched.yaml")
+	require.NoError(t, err)
+
+	merged, err := overlay.Merge(
+		overlay.MergeSource{Name: "overlay.yaml", Overlay: o1},
+		overlay.MergeSource{Name: "overlay-mismatched.yaml", Overlay: o2},
+	)
+	require.NoError(t, err)
+	require.NoError(t, merged.Validate())
+
+	assert.Equal(t, "Drinks Overlay", merged.Info.Title)
+	assert.Equal(t, "0.0.0", merged.Info.Version)
+	assert.Equal(t, 42, merged.Info.Extensions["x-info-extension"])
+	assert.Equal(t, true, merged.Extensions["x-top-level-extension"])
+	assert.True(t, merged.UsesRFC9535())
+
+	require.Len(t, merged.Actions, len(o1.Actions)+len(o2.Actions))
+	assert.Equal(t, "overlay.yaml#/actions/0", merged.Actions[0].Extensions[overlay.SourceExtension])
+	assert.Equal(t, "foo", merged.Actions[0].Extensions["x-action-extension"])
+	assert.Equal(t, "overlay-mismatched.yaml#/actions/2", merged.Actions[len(merged.Actions)-1].Extensions[overlay.SourceExtension])
+
+	// the inputs are not modified
+	assert.NotContains(t, o1.Actions[0].Extensions, overlay.SourceExtension)
+}
+
+func TestMerge_IncompatibleJSONPath(t *testing.T) {
+	t.Parallel()
+
+	o1, err := loader.LoadOverlay("testdata/overlay.yaml")
+	require.NoError(t, err)
+	o2, err := loader.LoadOverlay("testdata/overlay-old.yaml")
+	require.NoError(t, err)
+
+	_, err = overlay.Merge(
+		overlay.MergeSource{Name: "overlay.yaml", Overlay: o1},
+		overlay.MergeSource{Name: "overlay-old.yaml", Overlay: o2},
+	)
+	assert.ErrorContains(t, err, `overlay "overlay-old.yaml" action at index 0 cannot be upgraded to rfc9535 jsonpath`)
+}
+
+func TestMerge_ConflictingExtends(t *testing.T) {
+	t.Parallel()
+
+	o1 := &overlay.Overlay{Extends: "file:///a.yaml"}
+	o2 := &overlay.Overlay{Extends: "file:///b.yaml"}
+
+	_, err := overlay.Merge(
+		overlay.MergeSource{Name: "a", Overlay: o1},
+		overlay.MergeSource{Name: "b", Overlay: o2},
+	)
+	assert.ErrorContains(t, err, `overlay "b" extends "file:///b.yaml"`)
+}
+
+func TestMerge_ConflictingDocument(t *testing.T) {
+	t.Parallel()
+
+	first, second := 0, 1
+	o1 := &overlay.Overlay{Document: &first}
+	o2 := &overlay.Overlay{}
+	o3 := &overlay.Overlay{Document: &second}
+
+	merged, err := overlay.Merge(
+		overlay.MergeSource{Name: "a", Overlay: o1},
+		overlay.MergeSource{Name: "b", Overlay: o2},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, &first, merged.Document)
+
+	_, err = overlay.Merge(
+		overlay.MergeSource{Name: "a", Overlay: o1},
+		overlay.MergeSource{Name: "c", Overlay: o3},
+	)
+	assert.ErrorContains(t, err, `overlay "c" applies to document 1, but previous overlays apply to document 0`)
+}
@@ -0,0 +1,324 @@
+package overlay
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// The schemas follow those published by the OpenAPI Initiative for each minor
+// version of the specification.
+var (
+	//go:embed schemas/oas-3.0.json
+	oas30Schema []byte
+	//go:embed schemas/oas-3.1.json
+	oas31Schema []byte
+)
+
+var loadOpenAPISchemas = sync.OnceValues(func() (map[string]*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	urls := map[string]string{}
+	for version, data := range map[string][]byte{"3.0": oas30Schema, "3.1": oas31Schema} {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the OpenAPI %s schema: %w", version, err)
+		}
+		url := "https://spec.openapis.org/oas/" + version + "/schema"
+		if err := compiler.AddResource(url, doc); err != nil {
+			return nil, fmt.Errorf("failed to load the OpenAPI %s schema: %w", version, err)
+		}
+		urls[version] = url
+	}
+
+	schemas := map[string]*jsonschema.Schema{}
+	for version, url := range urls {
+		schema, err := compiler.Compile(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile the OpenAPI %s schema: %w", version, err)
+		}
+		schemas[version] = schema
+	}
+	return schemas, nil
+})
+
+// SpecViolation is a place where a document does not conform to the OpenAPI
+// specification.
+type SpecViolation struct {
+	// Path is the JSON pointer of the node that does not conform.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+
+	// Action is the index of the last action whose matched nodes contain the
+	// node, or whose removal left it incomplete, or -1 if there is none. It is
+	// only set by ApplyToContext.
+	Action int `json:"action"`
+
+	// Target is the target of that action.
+	Target string `json:"target,omitempty"`
+
+	// Preexisting is set if the document already had the violation before
+	// the overlay was applied. Such violations are not attributed to an action.
+	Preexisting bool `json:"preexisting,omitempty"`
+}
+
+func (v SpecViolation) String() string {
+	switch {
+	case v.Preexisting:
+		return fmt.Sprintf("%s: %s (already present before the overlay was applied)", v.Path, v.Message)
+	case v.Action >= 0:
+		return fmt.Sprintf("%s: %s (caused by the action at index %d, target=%s)", v.Path, v.Message, v.Action, v.Target)
+	default:
+		return fmt.Sprintf("%s: %s", v.Path, v.Message)
+	}
+}
+
+// ValidateSpecification checks the document against the JSON Schema of the
+// OpenAPI version given by its openapi field, which must be 3.0.x or 3.1.x. It
+// returns the places where the document does not conform, ordered by path.
+func ValidateSpecification(root *yaml.Node) ([]SpecViolation, error) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+
+	versionNode := simplePath{keyPart("openapi")}.Resolve(doc)
+	if versionNode == nil || versionNode.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("document has no openapi version")
+	}
+	var version string
+	switch {
+	case strings.HasPrefix(versionNode.Value, "3.0."):
+		version = "3.0"
+	case strings.HasPrefix(versionNode.Value, "3.1."):
+		version = "3.1"
+	default:
+		return nil, fmt.Errorf("unsupported OpenAPI version %q: expected 3.0.x or 3.1.x", versionNode.Value)
+	}
+
+	schemas, err := loadOpenAPISchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	err = schemas[version].Validate(jsonValue(doc))
+	if err == nil {
+		return nil, nil
+	}
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
+	}
+
+	var found []foundViolation
+	collectSpecViolations(validationErr, message.NewPrinter(language.English), 0, &found)
+
+	var violations []SpecViolation
+	seen := map[string]struct{}{}
+	for _, v := range found {
+		key := v.Path + "\x00" + v.Message
+		if _, ok := seen[key]; ok || v.superseded(found) {
+			continue
+		}
+		seen[key] = struct{}{}
+		violations = append(violations, v.SpecViolation)
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+	return violations, nil
+}
+
+type foundViolation struct {
+	SpecViolation
+
+	// alternatives is the number of oneOf or anyOf keywords the violation was
+	// found under.
+	alternatives int
+}
+
+// superseded reports whether another violation at the same node, or at one of
+// its ancestors or descendants, was found under fewer alternatives. Such a
+// violation is more to the point, as the others only explain why the node
+// failed to match alternatives it was never meant to.
+func (v foundViolation) superseded(found []foundViolation) bool {
+	for _, other := range found {
+		if other.alternatives >= v.alternatives {
+			continue
+		}
+		if pointerContains(other.Path, v.Path) || pointerContains(v.Path, other.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointerContains reports whether the JSON pointer is equal to or below the
+// given ancestor.
+func pointerContains(ancestor, pointer string) bool {
+	return pointer == ancestor || strings.HasPrefix(pointer, ancestor+"/")
+}
+
+// collectSpecViolations collects the innermost errors of the validation error.
+// Where a node must match one of several alternatives, the alternatives that
+// only fail because the node is not a $ref are left out, as they say nothing
+// about what is actually wrong with it.
+func collectSpecViolations(err *jsonschema.ValidationError, printer *message.Printer, alternatives int, found *[]foundViolation) {
+	causes := err.Causes
+	switch err.ErrorKind.(type) {
+	case *kind.OneOf, *kind.AnyOf:
+		alternatives++
+		var relevant []*jsonschema.ValidationError
+		for _, cause := range causes {
+			if !onlyMissesRef(cause) {
+				relevant = append(relevant, cause)
+			}
+		}
+		if len(relevant) > 0 {
+			causes = relevant
+		}
+	}
+
+	if len(causes) == 0 {
+		path := simplePath{}
+		for _, token := range err.InstanceLocation {
+			path = append(path, keyPart(token))
+		}
+		msg := err.ErrorKind.LocalizedString(printer)
+		if _, ok := err.ErrorKind.(*kind.FalseSchema); ok && len(path) > 0 {
+			// unevaluatedProperties rejects each unknown property this way
+			path, msg = path.Dir(), fmt.Sprintf("additional property %q not allowed", path.Base().key)
+		}
+		*found = append(*found, foundViolation{
+			SpecViolation: SpecViolation{Path: path.ToJSONPointer(), Message: msg, Action: -1},
+			alternatives:  alternatives,
+		})
+		return
+	}
+	for _, cause := range causes {
+		collectSpecViolations(cause, printer, alternatives, found)
+	}
+}
+
+func onlyMissesRef(err *jsonschema.ValidationError) bool {
+	if len(err.Causes) == 0 {
+		required, ok := err.ErrorKind.(*kind.Required)
+		return ok && len(required.Missing) == 1 && required.Missing[0] == "$ref"
+	}
+	for _, cause := range err.Causes {
+		if !onlyMissesRef(cause) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonValue converts the node into the values encoding/json would decode the
+// equivalent JSON into, which is what the schema validator expects.
+func jsonValue(node *yaml.Node) any {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return jsonValue(node.Content[0])
+	case yaml.AliasNode:
+		return jsonValue(node.Alias)
+	case yaml.MappingNode:
+		out := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			out[node.Content[i].Value] = jsonValue(node.Content[i+1])
+		}
+		return out
+	case yaml.SequenceNode:
+		out := make([]any, len(node.Content))
+		for i, child := range node.Content {
+			out[i] = jsonValue(child)
+		}
+		return out
+	}
+
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	switch value.(type) {
+	case nil, bool, int, int64, uint64, float64, string:
+		return value
+	default:
+		return node.Value
+	}
+}
+
+// actionMatches records the nodes matched by each action, so that problems
+// found once an overlay is applied can be attributed to the action responsible.
+// Nodes are recorded rather than their paths, as later actions can move them.
+type actionMatches []actionMatch
+
+type actionMatch struct {
+	action int
+	target string
+	remove bool
+
+	// nodes are the matched nodes or, for a remove, the nodes they were
+	// removed from.
+	nodes map[*yaml.Node]struct{}
+}
+
+func (m *actionMatches) record(state *applyState, i int, action Action, nodes []*yaml.Node) {
+	if m == nil || len(nodes) == 0 {
+		return
+	}
+	match := actionMatch{action: i, target: action.Target, remove: action.Remove, nodes: map[*yaml.Node]struct{}{}}
+	for _, node := range nodes {
+		if action.Remove {
+			node = state.parents().getParent(node)
+		}
+		if node != nil {
+			match.nodes[node] = struct{}{}
+		}
+	}
+	*m = append(*m, match)
+}
+
+// attribute sets the action of each violation that was not already present
+// before the overlay was applied: the last action whose matched nodes contain
+// the violating node, or that removed a child of it, leaving it incomplete.
+func (m actionMatches) attribute(root *yaml.Node, violations []SpecViolation, before []SpecViolation) {
+	preexisting := map[string]struct{}{}
+	for _, violation := range before {
+		preexisting[violation.Path+"\x00"+violation.Message] = struct{}{}
+	}
+
+	idx := newParentIndex(root)
+	for i := range violations {
+		violation := &violations[i]
+		if _, ok := preexisting[violation.Path+"\x00"+violation.Message]; ok {
+			violation.Preexisting = true
+			continue
+		}
+		node := resolveRef(root, violation.Path)
+		if node == nil {
+			continue
+		}
+		for j := len(m) - 1; j >= 0 && violation.Action < 0; j-- {
+			for n := node; n != nil; n = idx.getParent(n) {
+				if _, ok := m[j].nodes[n]; ok {
+					violation.Action = m[j].action
+					violation.Target = m[j].target
+					break
+				}
+				if m[j].remove {
+					// only the node a child was removed from is incomplete
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateSpecification(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	violations, err := overlay.ValidateSpecification(node)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected []overlay.SpecViolation
+	}{
+		{
+			name: "3.0",
+			spec: `openapi: 3.0.3
+info: {title: Drinks, version: 1.0.0}
+paths:
+  /drinks:
+    get:
+      parameters:
+        - $ref: "#/components/parameters/Limit"
+        - name: type
+          schema: {type: string}
+      responses:
+        "200": {description: OK}
+    post: {}
+`,
+			expected: []overlay.SpecViolation{
+				{Path: "#/paths/~1drinks/get/parameters/1", Message: "missing property 'in'", Action: -1},
+				{Path: "#/paths/~1drinks/post", Message: "missing property 'responses'", Action: -1},
+			},
+		},
+		{
+			name: "3.1",
+			spec: `openapi: 3.1.0
+info: {title: Drinks}
+webhooks:
+  drinkAdded:
+    post:
+      x-speakeasy-name: onDrinkAdded
+      summary: 1
+      responses:
+        "200": {content: {}}
+`,
+			expected: []overlay.SpecViolation{
+				{Path: "#/info", Message: "missing property 'version'", Action: -1},
+				{Path: "#/webhooks/drinkAdded/post/responses/200", Message: "missing property 'description'", Action: -1},
+				{Path: "#/webhooks/drinkAdded/post/summary", Message: "got number, want string", Action: -1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var node yaml.Node
+			require.NoError(t, yaml.Unmarshal([]byte(tt.spec), &node))
+			violations, err := overlay.ValidateSpecification(&node)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, violations)
+		})
+	}
+
+	var swagger yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("swagger: \"2.0\"\n"), &swagger))
+	_, err = overlay.ValidateSpecification(&swagger)
+	assert.ErrorContains(t, err, "document has no openapi version")
+}
+
+func TestApplyToContext_ValidateSpec(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`openapi: 3.0.3
+info: {title: Drinks, version: 1.0.0}
+paths:
+  /drinks:
+    get:
+      responses:
+        "200": {description: OK}
+    post:
+      responses:
+        "200": {description: OK}
+  /orders:
+    get: {}
+tags:
+  - name: drinks
+  - name: orders
+`), &node))
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Break the spec
+  version: 0.0.0
+actions:
+  - target: $.paths.*.get
+    update:
+      description: Lists things.
+  - target: $.paths["/drinks"].get
+    update:
+      parameters:
+        - name: type
+          in: body
+          schema: {type: string}
+  - target: $.paths["/drinks"].post.responses
+    remove: true
+  - target: $.tags[1]
+    update:
+      externalDocs: {description: Orders}
+  - target: $.tags[0]
+    remove: true
+`), &o))
+
+	result, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{ValidateSpec: true})
+	require.NoError(t, err)
+	assert.Equal(t, []overlay.SpecViolation{
+		{Path: "#/paths/~1drinks/get/parameters/0/in", Message: "value must be one of 'query', 'header', 'path', 'cookie'", Action: 1, Target: `$.paths["/drinks"].get`},
+		{Path: "#/paths/~1drinks/post", Message: "missing property 'responses'", Action: 2, Target: `$.paths["/drinks"].post.responses`},
+		{Path: "#/paths/~1orders/get", Message: "missing property 'responses'", Action: -1, Preexisting: true},
+		// the tag was updated at index 1, but has since moved to index 0
+		{Path: "#/tags/0/externalDocs", Message: "missing property 'url'", Action: 3, Target: "$.tags[1]"},
+	}, result.SpecViolations)
+
+	result, err = o.ApplyToContext(context.Background(), &yaml.Node{Kind: yaml.MappingNode}, &overlay.ApplyOptions{ValidateSpec: true})
+	assert.ErrorContains(t, err, "document has no openapi version")
+	assert.Empty(t, result.SpecViolations)
+}
@@ -0,0 +1,187 @@
+package overlay
+
+import "fmt"
+
+// ApplyOptions configures how ApplyToContext applies an overlay.
+type ApplyOptions struct {
+	// Strict fails the apply if any selector matches nothing, as ApplyToStrict
+	// does. All actions are still applied.
+	Strict bool
+
+	// Observer, if set, is called when each action starts and finishes, or is
+	// skipped. It is called synchronously, so it should return quickly.
+	Observer func(ActionEvent)
+
+	// DanglingRefs decides what is done with local $refs that no longer resolve
+	// once the overlay has been applied, such as refs to a removed component.
+	// Refs that were already dangling before the overlay was applied are left
+	// alone.
+	DanglingRefs DanglingRefPolicy
+
+	// ValidateSpec checks the document against the OpenAPI schema once the
+	// overlay has been applied, attributing any violations to the actions
+	// that caused them. The document must declare an OpenAPI version of 3.0.x
+	// or 3.1.x.
+	ValidateSpec bool
+
+	// SourceMap records which action last created or changed each node of the
+	// document, in ApplyResult.SourceMap.
+	SourceMap bool
+
+	// ProvenanceComments adds a line comment naming the action responsible to
+	// each node an action created or changed, such as
+	//
+	//	# overlay: actions[4] "Add retries"
+	ProvenanceComments bool
+
+	// MinimalDiff keeps the comments of nodes that an update replaces with a
+	// node of a different kind. Along with encoding the result with
+	// Document.Encode, it leaves everything the overlay did not change as it
+	// was in the source text.
+	MinimalDiff bool
+
+	// Vars are the variables that x-when conditions are evaluated against,
+	// and that are interpolated into updates when Interpolate is set. If it is
+	// nil, every action with an x-when condition is skipped.
+	Vars map[string]string
+
+	// Interpolate replaces each ${name} in the scalars of an update with the
+	// value of the variable from Vars, failing if it is not set. $${ stands
+	// for a literal ${. The overlay itself is left unchanged.
+	Interpolate bool
+}
+
+// DanglingRefPolicy decides what is done with the $refs an overlay leaves
+// dangling.
+type DanglingRefPolicy string
+
+const (
+	// DanglingRefsIgnore leaves dangling refs as they are. It is the default.
+	DanglingRefsIgnore DanglingRefPolicy = "ignore"
+	// DanglingRefsReport leaves dangling refs as they are but lists them in
+	// the result.
+	DanglingRefsReport DanglingRefPolicy = "report"
+	// DanglingRefsRemove removes the nodes holding dangling refs, and so on for
+	// any refs to those nodes.
+	DanglingRefsRemove DanglingRefPolicy = "remove"
+	// DanglingRefsRewrite points refs at the new location of the node they
+	// pointed at, when that node was moved rather than removed, such as by
+	// renaming a component. Refs to removed nodes are only reported.
+	DanglingRefsRewrite DanglingRefPolicy = "rewrite"
+)
+
+// ParseDanglingRefPolicy checks that the given string is a known policy.
+func ParseDanglingRefPolicy(s string) (DanglingRefPolicy, error) {
+	switch policy := DanglingRefPolicy(s); policy {
+	case "", DanglingRefsIgnore:
+		return DanglingRefsIgnore, nil
+	case DanglingRefsReport, DanglingRefsRemove, DanglingRefsRewrite:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown dangling ref policy %q, expected ignore, report, remove or rewrite", s)
+	}
+}
+
+func (opts *ApplyOptions) notify(event ActionEvent) {
+	if opts.Observer != nil {
+		opts.Observer(event)
+	}
+}
+
+// ApplyResult describes the outcome of applying an overlay.
+type ApplyResult struct {
+	// Warnings are problems found while applying the overlay that did not stop
+	// it being applied. Outside strict mode, only actions with an x-on-no-match
+	// policy of warn add to them.
+	Warnings []string
+
+	// DanglingRefs are the refs left dangling by the overlay, and what was done
+	// with them. It is only set when ApplyOptions.DanglingRefs is not ignore.
+	DanglingRefs []DanglingRef
+
+	// SpecViolations are the places where the document does not conform to the
+	// OpenAPI schema once the overlay has been applied. It is only set when
+	// ApplyOptions.ValidateSpec is set.
+	SpecViolations []SpecViolation
+
+	// SourceMap maps the nodes created or changed by the overlay to the
+	// actions responsible. It is only set when ApplyOptions.SourceMap is set.
+	SourceMap *SourceMap
+
+	// Skipped are the actions that were not applied, as their x-when
+	// condition did not hold.
+	Skipped []SkippedAction
+}
+
+// DanglingRef is a local $ref that no longer resolved after an overlay was
+// applied.
+type DanglingRef struct {
+	// Ref is the value the ref had once the overlay was applied.
+	Ref string `json:"ref"`
+
+	// Path is the JSON pointer of the node holding the ref.
+	Path string `json:"path"`
+
+	// NewRef is the value the ref was rewritten to, if it was.
+	NewRef string `json:"newRef,omitempty"`
+
+	// Removed is set if the node holding the ref was removed.
+	Removed bool `json:"removed,omitempty"`
+}
+
+func (r DanglingRef) String() string {
+	switch {
+	case r.NewRef != "":
+		return fmt.Sprintf("$ref %q at %s was rewritten to %q", r.Ref, r.Path, r.NewRef)
+	case r.Removed:
+		return fmt.Sprintf("$ref %q at %s no longer resolved and was removed", r.Ref, r.Path)
+	default:
+		return fmt.Sprintf("$ref %q at %s no longer resolves", r.Ref, r.Path)
+	}
+}
+
+// ActionEventKind identifies the point in applying an action that an
+// ActionEvent reports.
+type ActionEventKind int
+
+const (
+	// ActionStarted is reported before an action's target is evaluated.
+	ActionStarted ActionEventKind = iota
+	// ActionFinished is reported once an action has been applied.
+	ActionFinished
+	// ActionSkipped is reported instead of the other events for an action
+	// whose x-when condition does not hold.
+	ActionSkipped
+)
+
+func (k ActionEventKind) String() string {
+	switch k {
+	case ActionStarted:
+		return "started"
+	case ActionFinished:
+		return "finished"
+	case ActionSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
+// ActionEvent reports progress applying the actions of an overlay.
+type ActionEvent struct {
+	Kind ActionEventKind
+
+	// Index is the index of the action in the overlay, out of Total actions.
+	Index int
+	Total int
+
+	Action *Action
+
+	// Matches is the number of nodes the action's target selected. It is only
+	// set once the action has finished.
+	Matches int
+
+	// Err is the error the action failed with, if any. It is only set once the
+	// action has finished.
+	Err error
+}
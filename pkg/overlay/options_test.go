@@ -0,0 +1,128 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyToContext_Observer(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay-mismatched.yaml")
+	require.NoError(t, err)
+
+	var events []overlay.ActionEvent
+	result, err := o.ApplyToContext(context.Background(), node, &overlay.ApplyOptions{
+		Strict: true,
+		Observer: func(event overlay.ActionEvent) {
+			events = append(events, event)
+		},
+	})
+	assert.ErrorContains(t, err, `selector "$[\"unknown-attribute\"]" did not match any targets`)
+	assert.Len(t, result.Warnings, 2)
+
+	require.Len(t, events, 2*len(o.Actions))
+	for i, event := range events {
+		assert.Equal(t, i/2, event.Index)
+		assert.Equal(t, len(o.Actions), event.Total)
+		if i%2 == 0 {
+			assert.Equal(t, overlay.ActionStarted, event.Kind)
+		} else {
+			assert.Equal(t, overlay.ActionFinished, event.Kind)
+		}
+	}
+	assert.Error(t, events[1].Err)
+	assert.Equal(t, 0, events[1].Matches)
+	assert.NoError(t, events[3].Err)
+	assert.Equal(t, 1, events[3].Matches)
+}
+
+func TestApplyToContext_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay.yaml")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	finished := 0
+	_, err = o.ApplyToContext(ctx, node, &overlay.ApplyOptions{
+		Observer: func(event overlay.ActionEvent) {
+			if event.Kind == overlay.ActionFinished {
+				finished++
+				cancel()
+			}
+		},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, finished)
+}
+
+func TestApplyToContext_CancelledBeforeQuery(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Cancelled
+  version: 0.0.0
+actions:
+  - target: $.info
+    update:
+      title: Drinks
+`), &o))
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+
+	_, err := o.ApplyToContext(ctx, &node, &overlay.ApplyOptions{
+		Observer: func(event overlay.ActionEvent) {
+			if event.Kind == overlay.ActionStarted {
+				cancel()
+			}
+		},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	actual, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Equal(t, "info: {title: Bar}\n", string(actual), "the action should not have been applied")
+}
+
+func TestApplyToContext_WarningsOnlyWhenStrict(t *testing.T) {
+	t.Parallel()
+
+	o, err := loader.LoadOverlay("testdata/overlay-mismatched.yaml")
+	require.NoError(t, err)
+	o.Actions = o.Actions[1:]
+
+	for _, strict := range []bool{true, false} {
+		node, err := loader.LoadSpecification("testdata/openapi.yaml")
+		require.NoError(t, err)
+
+		result, err := o.ApplyToContext(context.Background(), node, &overlay.ApplyOptions{Strict: strict})
+		require.NoError(t, err)
+		if strict {
+			assert.Equal(t, []string{"update action (2 / 2) target=$.info.title: does nothing"}, result.Warnings)
+		} else {
+			assert.Empty(t, result.Warnings, "actions that do nothing should only be reported in strict mode")
+		}
+	}
+}
@@ -1,23 +1,168 @@
 package overlay
 
-import "gopkg.in/yaml.v3"
+import (
+	"slices"
+	"sort"
 
-type parentIndex map[*yaml.Node]*yaml.Node
+	"gopkg.in/yaml.v3"
+)
+
+// parentIndex maps each node of a document to its parent and to where it is in
+// its parent's content, so that neither has to be searched for.
+type parentIndex struct {
+	parents map[*yaml.Node]*yaml.Node
+
+	// positions are where each child was in its parent's content when the
+	// parent was indexed. Rather than renumber every later sibling each time a
+	// child is removed, removed holds the positions removed from each parent
+	// since, in order, and a child's position is what was recorded for it less
+	// the number of removed positions before it.
+	positions map[*yaml.Node]int
+	removed   map[*yaml.Node][]int
+}
 
 // newParentIndex returns a new parentIndex, populated for the given root node.
-func newParentIndex(root *yaml.Node) parentIndex {
-	index := parentIndex{}
+func newParentIndex(root *yaml.Node) *parentIndex {
+	index := &parentIndex{
+		parents:   map[*yaml.Node]*yaml.Node{},
+		positions: map[*yaml.Node]int{},
+		removed:   map[*yaml.Node][]int{},
+	}
 	index.indexNodeRecursively(root)
 	return index
 }
 
-func (index parentIndex) indexNodeRecursively(parent *yaml.Node) {
-	for _, child := range parent.Content {
-		index[child] = parent
+func (index *parentIndex) indexNodeRecursively(parent *yaml.Node) {
+	index.indexChildren(parent, 0)
+}
+
+// indexChildren indexes the children of parent from the given position onwards,
+// along with all of their descendants. It does nothing for a nil index, so that
+// callers can keep an index up to date only once one has been built.
+func (index *parentIndex) indexChildren(parent *yaml.Node, from int) {
+	if index == nil {
+		return
+	}
+	if from == 0 {
+		delete(index.removed, parent)
+	}
+	// children are only ever added at the end, after every removed position
+	offset := len(index.removed[parent])
+	for i, child := range parent.Content[from:] {
+		index.parents[child] = parent
+		index.positions[child] = from + i + offset
 		index.indexNodeRecursively(child)
 	}
 }
 
-func (index parentIndex) getParent(child *yaml.Node) *yaml.Node {
-	return index[child]
+// reindexChildren indexes the children of parent again after they were added,
+// removed or reordered. Children new to parent are indexed along with their
+// descendants. It does nothing for a nil index.
+func (index *parentIndex) reindexChildren(parent *yaml.Node) {
+	if index == nil {
+		return
+	}
+	delete(index.removed, parent)
+	for i, child := range parent.Content {
+		if index.parents[child] != parent {
+			index.parents[child] = parent
+			index.indexNodeRecursively(child)
+		}
+		index.positions[child] = i
+	}
+}
+
+// drop removes a detached node and all of its descendants from the index.
+func (index *parentIndex) drop(node *yaml.Node) {
+	delete(index.parents, node)
+	delete(index.positions, node)
+	delete(index.removed, node)
+	for _, child := range node.Content {
+		if index.parents[child] == node {
+			index.drop(child)
+		}
+	}
+}
+
+func (index *parentIndex) getParent(child *yaml.Node) *yaml.Node {
+	return index.parents[child]
+}
+
+// position returns where the child is in its parent's content, or -1 if it is
+// no longer there.
+func (index *parentIndex) position(parent, child *yaml.Node) int {
+	recorded, ok := index.positions[child]
+	if ok {
+		p := recorded - sort.SearchInts(index.removed[parent], recorded)
+		if p >= 0 && p < len(parent.Content) && parent.Content[p] == child {
+			return p
+		}
+	}
+
+	// the parent was changed without the index being told
+	delete(index.removed, parent)
+	for i, node := range parent.Content {
+		index.positions[node] = i
+	}
+	return slices.Index(parent.Content, child)
+}
+
+// removeChildren removes the children at the given positions, which must be
+// in order, from the parent's content in one pass, and drops them from the
+// index.
+func (index *parentIndex) removeChildren(parent *yaml.Node, positions []int) {
+	for _, p := range positions {
+		index.drop(parent.Content[p])
+	}
+
+	// record the positions as they were when the parent was indexed: each is
+	// after as many removed positions as there are before it
+	removed := index.removed[parent]
+	recorded := make([]int, len(positions))
+	for i, p := range positions {
+		recorded[i] = p + sort.Search(len(removed), func(j int) bool { return removed[j]-j > p })
+	}
+	for _, r := range recorded {
+		removed = slices.Insert(removed, sort.SearchInts(removed, r), r)
+	}
+	index.removed[parent] = removed
+
+	content := parent.Content[:positions[0]]
+	for i, p := range positions {
+		end := len(parent.Content)
+		if i+1 < len(positions) {
+			end = positions[i+1]
+		}
+		content = append(content, parent.Content[p+1:end]...)
+	}
+	clear(parent.Content[len(content):])
+	parent.Content = content
+}
+
+// pathTo returns the normalized path from the document root to the given node.
+// Selecting a mapping key yields the same path as selecting its value.
+func (index *parentIndex) pathTo(node *yaml.Node) simplePath {
+	var reversed simplePath
+	for parent := index.getParent(node); parent != nil; node, parent = parent, index.getParent(parent) {
+		i := index.position(parent, node)
+		if i < 0 {
+			continue
+		}
+		switch parent.Kind {
+		case yaml.MappingNode:
+			if i%2 == 1 {
+				reversed = append(reversed, keyPart(parent.Content[i-1].Value))
+			} else {
+				reversed = append(reversed, keyPart(node.Value))
+			}
+		case yaml.SequenceNode:
+			reversed = append(reversed, intPart(i))
+		}
+	}
+
+	path := make(simplePath, len(reversed))
+	for i, part := range reversed {
+		path[len(reversed)-1-i] = part
+	}
+	return path
 }
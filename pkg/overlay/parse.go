@@ -1,9 +1,11 @@
 package overlay
 
 import (
+	"bytes"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -21,10 +23,15 @@ func Parse(path string) (*Overlay, error) {
 	}
 	defer ro.Close()
 
+	return ParseReader(ro)
+}
+
+// ParseReader will parse the YAML or JSON overlay read from r.
+func ParseReader(r io.Reader) (*Overlay, error) {
 	var overlay Overlay
-	dec := yaml.NewDecoder(ro)
+	dec := yaml.NewDecoder(r)
 
-	err = dec.Decode(&overlay)
+	err := dec.Decode(&overlay)
 	if err != nil {
 		return nil, err
 	}
@@ -32,6 +39,53 @@ func Parse(path string) (*Overlay, error) {
 	return &overlay, err
 }
 
+// ParseBytes will parse the given YAML or JSON overlay.
+func ParseBytes(data []byte) (*Overlay, error) {
+	return ParseReader(bytes.NewReader(data))
+}
+
+// ParseFS will parse the named overlay file in fsys, such as an embed.FS.
+func ParseFS(fsys fs.FS, name string) (*Overlay, error) {
+	ro, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open overlay file at path %q: %w", name, err)
+	}
+	defer ro.Close()
+
+	return ParseReader(ro)
+}
+
+// WriteFS is a file system that files can be written to, as needed by
+// FormatFS and Bundle.WriteTo.
+type WriteFS interface {
+	fs.FS
+
+	// WriteFile writes data to the named file, creating it and its directory
+	// if necessary.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// DirFS returns a WriteFS for the files in the given directory.
+func DirFS(dir string) WriteFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	path := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
 // Format will validate reformat the given file
 func Format(path string) error {
 	overlay, err := Parse(path)
@@ -50,6 +104,21 @@ func Format(path string) error {
 	return os.WriteFile(filePath, []byte(formatted), 0644)
 }
 
+// FormatFS will reformat the named overlay file in fsys, writing it back in
+// place.
+func FormatFS(fsys WriteFS, name string) error {
+	overlay, err := ParseFS(fsys, name)
+	if err != nil {
+		return err
+	}
+	formatted, err := overlay.ToString()
+	if err != nil {
+		return err
+	}
+
+	return fsys.WriteFile(name, []byte(formatted), 0644)
+}
+
 // Format writes the file back out as YAML.
 func (o *Overlay) Format(w io.Writer) error {
 	enc := yaml.NewEncoder(w)
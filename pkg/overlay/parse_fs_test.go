@@ -0,0 +1,82 @@
+package overlay_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unformattedOverlay = `{"overlay": "1.0.0", "info": {"title": "Drinks", "version": "1.0.0"},
+  "actions": [{"target": "$.info", "update": {"x-drinks": true}}]}`
+
+const formattedOverlay = `overlay: 1.0.0
+info:
+  title: Drinks
+  version: 1.0.0
+actions:
+  - target: $.info
+    update: {"x-drinks": true}
+`
+
+// mapWriteFS is a WriteFS that keeps its files in memory.
+type mapWriteFS struct {
+	fstest.MapFS
+}
+
+func (m mapWriteFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return nil
+}
+
+func TestParseBytes(t *testing.T) {
+	t.Parallel()
+
+	o, err := overlay.ParseBytes([]byte(unformattedOverlay))
+	require.NoError(t, err)
+	assert.Equal(t, "Drinks", o.Info.Title)
+	require.Len(t, o.Actions, 1)
+
+	o2, err := overlay.ParseReader(strings.NewReader(unformattedOverlay))
+	require.NoError(t, err)
+	assert.Equal(t, o, o2)
+
+	_, err = overlay.ParseBytes([]byte("actions: {"))
+	assert.Error(t, err)
+}
+
+func TestParseFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := mapWriteFS{fstest.MapFS{"overlays/drinks.json": {Data: []byte(unformattedOverlay)}}}
+	o, err := overlay.ParseFS(fsys, "overlays/drinks.json")
+	require.NoError(t, err)
+	assert.Equal(t, "$.info", o.Actions[0].Target)
+
+	_, err = overlay.ParseFS(fsys, "overlays/missing.yaml")
+	assert.ErrorContains(t, err, `failed to open overlay file at path "overlays/missing.yaml"`)
+
+	require.NoError(t, overlay.FormatFS(fsys, "overlays/drinks.json"))
+	assert.Equal(t, formattedOverlay, string(fsys.MapFS["overlays/drinks.json"].Data))
+}
+
+func TestDirFS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "overlay.yaml"), []byte(unformattedOverlay), 0644))
+
+	fsys := overlay.DirFS(dir)
+	require.NoError(t, overlay.FormatFS(fsys, "overlay.yaml"))
+	data, err := os.ReadFile(filepath.Join(dir, "overlay.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, formattedOverlay, string(data))
+
+	assert.Error(t, fsys.WriteFile("../escape.yaml", nil, 0644))
+}
@@ -0,0 +1,142 @@
+package overlay
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceMap records which action of an overlay last created or changed each
+// node of the document it was applied to.
+type SourceMap struct {
+	// Overlay is the file the overlay was loaded from. It is not set by
+	// ApplyToContext, which does not know it.
+	Overlay string `json:"overlay,omitempty"`
+
+	// Nodes maps the JSON pointer of each node to the action responsible. The
+	// descendants of a node created by an action are listed along with it. A
+	// renamed key is listed under the new key, along with its value, as are
+	// the nodes custom actions report changing.
+	Nodes map[string]SourceMapEntry `json:"nodes"`
+}
+
+// SourceMapEntry identifies the action that created or changed a node.
+type SourceMapEntry struct {
+	// Action is the index of the action in the overlay.
+	Action      int    `json:"action"`
+	Description string `json:"description,omitempty"`
+
+	// Line is the line of the action in the overlay file, or 0 if the overlay
+	// was not parsed from a file.
+	Line int `json:"line,omitempty"`
+}
+
+// provenance tracks the nodes changed by each action while an overlay is
+// applied.
+type provenance struct {
+	action  int
+	touched map[*yaml.Node]int
+}
+
+func newProvenance() *provenance {
+	return &provenance{touched: map[*yaml.Node]int{}}
+}
+
+func (p *provenance) touch(node *yaml.Node) {
+	p.touched[node] = p.action
+}
+
+type touchedNode struct {
+	node    *yaml.Node
+	parent  *yaml.Node
+	action  int
+	pointer string
+}
+
+// nodes returns the touched nodes still in the document, in the order they
+// were last changed.
+func (p *provenance) nodes(root *yaml.Node) []touchedNode {
+	idx := newParentIndex(root)
+	var nodes []touchedNode
+	for node, action := range p.touched {
+		parent := idx.getParent(node)
+		if node != root && parent == nil {
+			continue
+		}
+		nodes = append(nodes, touchedNode{node: node, parent: parent, action: action, pointer: idx.pathTo(node).ToJSONPointer()})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].action != nodes[j].action {
+			return nodes[i].action < nodes[j].action
+		}
+		return nodes[i].pointer < nodes[j].pointer
+	})
+	return nodes
+}
+
+// sourceMap lists each touched node, and the descendants of each, against the
+// action that last changed it.
+func (p *provenance) sourceMap(root *yaml.Node, actions []compiledAction) *SourceMap {
+	sm := &SourceMap{Nodes: map[string]SourceMapEntry{}}
+	for _, touched := range p.nodes(root) {
+		action := actions[touched.action].Action
+		entry := SourceMapEntry{Action: touched.action, Description: action.Description, Line: action.line}
+		var add func(node *yaml.Node, pointer string)
+		add = func(node *yaml.Node, pointer string) {
+			sm.Nodes[pointer] = entry
+			switch node.Kind {
+			case yaml.MappingNode:
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					add(node.Content[i+1], pointer+"/"+jsonPointerEscaper.Replace(node.Content[i].Value))
+				}
+			case yaml.SequenceNode:
+				for i, child := range node.Content {
+					add(child, pointer+"/"+strconv.Itoa(i))
+				}
+			}
+		}
+		add(touched.node, touched.pointer)
+	}
+	return sm
+}
+
+// annotate adds a comment naming the responsible action to each touched node.
+// Existing comments are kept.
+func (p *provenance) annotate(root *yaml.Node, actions []compiledAction) {
+	for _, touched := range p.nodes(root) {
+		comment := provenanceComment(touched.action, actions[touched.action].Description)
+		node := touched.node
+		block := (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && node.Style&yaml.FlowStyle == 0
+		switch {
+		case !block:
+			appendComment(&node.LineComment, " ", comment)
+		case touched.parent != nil && touched.parent.Kind == yaml.MappingNode:
+			// the encoder only keeps line comments on scalars and flow
+			// collections, so the comment goes after the key instead
+			for i := 1; i < len(touched.parent.Content); i += 2 {
+				if touched.parent.Content[i] == node {
+					appendComment(&touched.parent.Content[i-1].LineComment, " ", comment)
+					break
+				}
+			}
+		default:
+			appendComment(&node.HeadComment, "\n", comment)
+		}
+	}
+}
+
+func appendComment(existing *string, separator, comment string) {
+	if *existing != "" {
+		comment = *existing + separator + comment
+	}
+	*existing = comment
+}
+
+func provenanceComment(action int, description string) string {
+	if description == "" {
+		return fmt.Sprintf("# overlay: actions[%d]", action)
+	}
+	return fmt.Sprintf("# overlay: actions[%d] %q", action, description)
+}
@@ -0,0 +1,74 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyToContext_Provenance(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`openapi: 3.1.0
+info:
+  title: Drinks # the name
+  version: 1.0.0
+tags:
+  - name: drinks
+`), &node))
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`overlay: 1.0.0
+info:
+  title: Provenance
+  version: 0.0.0
+actions:
+  - target: $.info
+    description: Add retries
+    update:
+      title: Drinks
+      x-retries:
+        backoff: 10
+  - target: $.tags
+    update:
+      - name: orders
+  - target: $.info
+    update:
+      x-retries: {backoff: 20}
+  - target: $.info.version
+    x-speakeasy-rename: apiVersion
+`), &o))
+
+	result, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{SourceMap: true, ProvenanceComments: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]overlay.SourceMapEntry{
+		"#/info/x-retries":         {Action: 0, Description: "Add retries", Line: 6},
+		"#/info/x-retries/backoff": {Action: 2, Line: 15},
+		"#/info/apiVersion":        {Action: 3, Line: 18},
+		"#/tags/1":                 {Action: 1, Line: 12},
+		"#/tags/1/name":            {Action: 1, Line: 12},
+	}, result.SourceMap.Nodes, "unchanged nodes should not be listed, and later actions should win")
+
+	out, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	assert.Equal(t, `openapi: 3.1.0
+info:
+    title: Drinks # the name
+    apiVersion: 1.0.0 # overlay: actions[3]
+    x-retries: # overlay: actions[0] "Add retries"
+        backoff: 20 # overlay: actions[2]
+tags:
+    - name: drinks
+    # overlay: actions[1]
+    - name: orders
+`, string(out))
+
+	result, err = o.ApplyToContext(context.Background(), &node, nil)
+	require.NoError(t, err)
+	assert.Nil(t, result.SourceMap)
+}
@@ -0,0 +1,272 @@
+package overlay
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PruneOptions configures Prune.
+type PruneOptions struct {
+	// Keep lists components that must not be removed even if nothing refers to
+	// them, as <type>/<name> patterns such as schemas/Error or
+	// securitySchemes/*, using the syntax of path.Match. Anything they refer to
+	// is kept too.
+	Keep []string
+}
+
+// Prune returns an overlay that removes the components of the given
+// specification that cannot be reached from outside the components section,
+// such as from paths, webhooks and security requirements. A component is
+// reachable if it is the target of a $ref, a discriminator mapping or a
+// security requirement found in a reachable part of the document, where
+// security requirements are those of the document and of its operations. The
+// given specification is not modified.
+//
+// If there is nothing to prune, the returned overlay is nil, as an overlay
+// must have at least one action.
+func Prune(root *yaml.Node, opts *PruneOptions) (*Overlay, error) {
+	if opts == nil {
+		opts = &PruneOptions{}
+	}
+	for _, pattern := range opts.Keep {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid keep pattern %q: %w", pattern, err)
+		}
+	}
+
+	pruned := &Overlay{
+		Version:         "1.0.0",
+		JSONPathVersion: "rfc9535",
+		Info: Info{
+			Title:   "Remove unreferenced components",
+			Version: "0.0.0",
+		},
+	}
+
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	p := &pruner{root: doc, reachable: map[string]struct{}{}}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		switch key, value := doc.Content[i].Value, doc.Content[i+1]; key {
+		case "components":
+		case "paths", "webhooks":
+			p.visitPathItems(value)
+		case "security":
+			p.markSecurity(value)
+		default:
+			p.visitEntry(key, value)
+		}
+	}
+
+	components := simplePath{keyPart("components")}.Resolve(doc)
+	if components == nil || components.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(components.Content); i += 2 {
+		kind, entries := components.Content[i].Value, components.Content[i+1]
+		if entries.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(entries.Content); j += 2 {
+			if keepComponent(opts.Keep, kind, entries.Content[j].Value) {
+				p.mark(kind, entries.Content[j].Value)
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(components.Content); i += 2 {
+		kind, entries := components.Content[i].Value, components.Content[i+1]
+		if entries.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(entries.Content); j += 2 {
+			name := entries.Content[j].Value
+			if _, ok := p.reachable[kind+"/"+name]; ok {
+				continue
+			}
+			pruned.Actions = append(pruned.Actions, Action{
+				Target:      simplePath{keyPart("components"), keyPart(kind), keyPart(name)}.ToJSONPath(),
+				Description: fmt.Sprintf("%s %s is not referenced", kind, name),
+				Remove:      true,
+			})
+		}
+	}
+
+	if len(pruned.Actions) == 0 {
+		return nil, nil
+	}
+	return pruned, nil
+}
+
+func keepComponent(patterns []string, kind, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, kind+"/"+name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pruner records the components reachable from the parts of the document it
+// visits.
+type pruner struct {
+	root      *yaml.Node
+	reachable map[string]struct{}
+}
+
+// mark records the component as reachable and visits it, if it has not been
+// already.
+func (p *pruner) mark(kind, name string) {
+	if _, ok := p.reachable[kind+"/"+name]; ok {
+		return
+	}
+	p.reachable[kind+"/"+name] = struct{}{}
+
+	node := simplePath{keyPart("components"), keyPart(kind), keyPart(name)}.Resolve(p.root)
+	switch {
+	case node == nil:
+	case kind == "pathItems":
+		p.visitPathItem(node)
+	case kind == "callbacks":
+		p.visitPathItems(node)
+	default:
+		p.visit(node)
+	}
+}
+
+// markRef marks the component a local ref points at, or into, as reachable.
+func (p *pruner) markRef(ref string) {
+	pointer, ok := strings.CutPrefix(ref, "#/components/")
+	if !ok {
+		return
+	}
+	segments := strings.SplitN(pointer, "/", 3)
+	if len(segments) < 2 {
+		return
+	}
+	for i, segment := range segments[:2] {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = jsonPointerUnescaper.Replace(segment)
+	}
+	p.mark(segments[0], segments[1])
+}
+
+func (p *pruner) visit(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			p.visit(node.Alias)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			p.visitEntry(node.Content[i].Value, node.Content[i+1])
+		}
+	default:
+		for _, child := range node.Content {
+			p.visit(child)
+		}
+	}
+}
+
+// visitEntry visits the value of a mapping entry, marking the components
+// referred to by the entry itself.
+func (p *pruner) visitEntry(key string, value *yaml.Node) {
+	switch {
+	case key == "$ref" && value.Kind == yaml.ScalarNode:
+		p.markRef(value.Value)
+	case key == "discriminator" && value.Kind == yaml.MappingNode:
+		if mapping := (simplePath{keyPart("mapping")}).Resolve(value); mapping != nil && mapping.Kind == yaml.MappingNode {
+			for i := 1; i < len(mapping.Content); i += 2 {
+				if target := mapping.Content[i].Value; strings.HasPrefix(target, "#") {
+					p.markRef(target)
+				} else {
+					p.mark("schemas", target)
+				}
+			}
+		}
+	}
+
+	p.visit(value)
+}
+
+// operationMethods are the fields of a path item that hold operations.
+var operationMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// visitPathItems visits a mapping of path items, such as paths, webhooks or a
+// callback.
+func (p *pruner) visitPathItems(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		p.visit(node)
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if key == "$ref" || strings.HasPrefix(key, "x-") {
+			p.visitEntry(key, value)
+		} else {
+			p.visitPathItem(value)
+		}
+	}
+}
+
+func (p *pruner) visitPathItem(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		p.visit(node)
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if operationMethods[key] && value.Kind == yaml.MappingNode {
+			p.visitOperation(value)
+		} else {
+			p.visitEntry(key, value)
+		}
+	}
+}
+
+func (p *pruner) visitOperation(node *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch key, value := node.Content[i].Value, node.Content[i+1]; {
+		case key == "security":
+			p.markSecurity(value)
+		case key == "callbacks" && value.Kind == yaml.MappingNode:
+			for j := 1; j < len(value.Content); j += 2 {
+				p.visitPathItems(value.Content[j])
+			}
+		default:
+			p.visitEntry(key, value)
+		}
+	}
+}
+
+// markSecurity marks the security schemes named by a list of security
+// requirements as reachable.
+func (p *pruner) markSecurity(node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, requirement := range node.Content {
+		if requirement.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(requirement.Content); i += 2 {
+			p.mark("securitySchemes", requirement.Content[i].Value)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const pruneSpec = `openapi: 3.1.0
+security:
+  - apiKey: []
+paths:
+  /drinks:
+    get:
+      responses:
+        "200":
+          $ref: "#/components/responses/Drinks"
+  /orders:
+    x-internal:
+      security:
+        - basic: []
+    post:
+      callbacks:
+        shipped:
+          $ref: "#/components/callbacks/Shipped"
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              examples:
+                secured:
+                  value:
+                    security:
+                      - basic: []
+webhooks:
+  orderPlaced:
+    post:
+      security:
+        - oauth: [orders]
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Order/properties/drink"
+components:
+  responses:
+    Drinks:
+      content:
+        application/json:
+          schema:
+            $ref: "#/components/schemas/Drink"
+    Unused:
+      description: not referenced
+  schemas:
+    Drink:
+      discriminator:
+        propertyName: type
+        mapping:
+          cocktail: Cocktail
+          beer: "#/components/schemas/Beer"
+    Cocktail:
+      type: object
+    Beer:
+      type: object
+    Order:
+      properties:
+        drink:
+          type: string
+    Orphan:
+      $ref: "#/components/schemas/OrphanChild"
+    OrphanChild:
+      type: object
+    Error:
+      type: object
+  callbacks:
+    Shipped:
+      "{$request.body#/callback}":
+        post:
+          security:
+            - partner: []
+  securitySchemes:
+    partner:
+      type: http
+    apiKey:
+      type: apiKey
+    oauth:
+      type: oauth2
+    basic:
+      type: http
+`
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(pruneSpec), &node))
+	original := cloneNode(&node)
+
+	pruned, err := overlay.Prune(&node, nil)
+	require.NoError(t, err)
+	assert.Equal(t, original, &node, "the spec should not be modified")
+
+	var targets []string
+	for _, action := range pruned.Actions {
+		assert.True(t, action.Remove)
+		targets = append(targets, action.Target)
+	}
+	assert.Equal(t, []string{
+		`$["components"]["responses"]["Unused"]`,
+		`$["components"]["schemas"]["Orphan"]`,
+		`$["components"]["schemas"]["OrphanChild"]`,
+		`$["components"]["schemas"]["Error"]`,
+		`$["components"]["securitySchemes"]["basic"]`,
+	}, targets)
+	assert.Equal(t, "schemas Orphan is not referenced", pruned.Actions[1].Description)
+
+	require.NoError(t, pruned.Validate())
+	require.NoError(t, pruned.ApplyTo(&node))
+	again, err := overlay.Prune(&node, nil)
+	require.NoError(t, err)
+	assert.Nil(t, again, "pruning should remove everything unreachable in one pass")
+}
+
+func TestPrune_Keep(t *testing.T) {
+	t.Parallel()
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(pruneSpec), &node))
+
+	pruned, err := overlay.Prune(&node, &overlay.PruneOptions{Keep: []string{"schemas/Orphan", "securitySchemes/*", "responses/Unused"}})
+	require.NoError(t, err)
+	require.Len(t, pruned.Actions, 1)
+	assert.Equal(t, `$["components"]["schemas"]["Error"]`, pruned.Actions[0].Target, "components referred to by kept components should be kept")
+
+	_, err = overlay.Prune(&node, &overlay.PruneOptions{Keep: []string{"schemas/["}})
+	assert.ErrorContains(t, err, `invalid keep pattern "schemas/["`)
+}
@@ -0,0 +1,197 @@
+package overlay
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// walkRefs calls fn for the value node of every $ref key found in the
+// document, along with the mapping containing it.
+func walkRefs(node *yaml.Node, fn func(ref *yaml.Node, mapping *yaml.Node)) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "$ref" && node.Content[i+1].Kind == yaml.ScalarNode {
+				fn(node.Content[i+1], node)
+			}
+		}
+	}
+	for _, child := range node.Content {
+		walkRefs(child, fn)
+	}
+}
+
+// rewriteRefs replaces the oldPointer prefix of every local $ref that points at
+// it, or below it, with newPointer. It returns the number of refs rewritten.
+func rewriteRefs(root *yaml.Node, oldPointer, newPointer string) int {
+	rewritten := 0
+	walkRefs(root, func(ref *yaml.Node, _ *yaml.Node) {
+		if ref.Value == oldPointer || strings.HasPrefix(ref.Value, oldPointer+"/") {
+			ref.Value = newPointer + strings.TrimPrefix(ref.Value, oldPointer)
+			rewritten++
+		}
+	})
+	return rewritten
+}
+
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// resolveRef returns the node a local $ref points at, or nil if the ref is not
+// local or does not resolve.
+func resolveRef(root *yaml.Node, ref string) *yaml.Node {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil
+	}
+
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	if pointer == "" {
+		return node
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil
+	}
+
+	for _, segment := range strings.Split(pointer[1:], "/") {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segment = jsonPointerUnescaper.Replace(segment)
+
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment {
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(segment); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// refSnapshot records what a $ref resolved to before an overlay was applied.
+type refSnapshot struct {
+	value string
+
+	// target is nil if the ref was already dangling.
+	target *yaml.Node
+}
+
+// snapshotRefs records what every local $ref in the document resolves to.
+func snapshotRefs(root *yaml.Node) map[*yaml.Node]refSnapshot {
+	refs := map[*yaml.Node]refSnapshot{}
+	walkRefs(root, func(ref *yaml.Node, _ *yaml.Node) {
+		if strings.HasPrefix(ref.Value, "#") {
+			refs[ref] = refSnapshot{value: ref.Value, target: resolveRef(root, ref.Value)}
+		}
+	})
+	return refs
+}
+
+type danglingRef struct {
+	ref     *yaml.Node
+	mapping *yaml.Node
+	before  refSnapshot
+	known   bool
+}
+
+// findDanglingRefs returns the local $refs in the document that no longer
+// resolve, leaving out those that were already dangling before the overlay was
+// applied.
+func findDanglingRefs(root *yaml.Node, before map[*yaml.Node]refSnapshot) []danglingRef {
+	var dangling []danglingRef
+	walkRefs(root, func(ref *yaml.Node, mapping *yaml.Node) {
+		if !strings.HasPrefix(ref.Value, "#") || resolveRef(root, ref.Value) != nil {
+			return
+		}
+		snapshot, known := before[ref]
+		if known && snapshot.value == ref.Value && snapshot.target == nil {
+			return
+		}
+		dangling = append(dangling, danglingRef{ref: ref, mapping: mapping, before: snapshot, known: known && snapshot.value == ref.Value})
+	})
+	return dangling
+}
+
+// handleDanglingRefs deals with the $refs that the overlay left dangling, as
+// the policy asks.
+func handleDanglingRefs(root *yaml.Node, before map[*yaml.Node]refSnapshot, policy DanglingRefPolicy) []DanglingRef {
+	var handled []DanglingRef
+	for {
+		dangling := findDanglingRefs(root, before)
+		if len(dangling) == 0 {
+			return handled
+		}
+
+		idx := newParentIndex(root)
+		progressed := false
+		for _, d := range dangling {
+			result := DanglingRef{Ref: d.ref.Value, Path: idx.pathTo(d.mapping).ToJSONPointer()}
+
+			switch policy {
+			case DanglingRefsRewrite:
+				// the node the ref pointed at may only have moved, as when its
+				// key was renamed
+				if d.known && d.before.target != nil && (idx.getParent(d.before.target) != nil || d.before.target == root) {
+					result.NewRef = idx.pathTo(d.before.target).ToJSONPointer()
+					d.ref.Value = result.NewRef
+				}
+			case DanglingRefsRemove:
+				if parent := idx.getParent(d.mapping); parent != nil && removeChild(parent, d.mapping) {
+					result.Removed = true
+					progressed = true
+				}
+			}
+
+			// stop checking the ref again on the next pass
+			before[d.ref] = refSnapshot{value: d.ref.Value}
+			handled = append(handled, result)
+		}
+
+		// removing nodes may leave refs to them dangling in turn
+		if !progressed {
+			return handled
+		}
+	}
+}
+
+// removeChild removes child from its parent mapping, along with its key, or
+// from its parent sequence. It reports whether the child was found.
+func removeChild(parent, child *yaml.Node) bool {
+	for i, node := range parent.Content {
+		if node != child {
+			continue
+		}
+		switch parent.Kind {
+		case yaml.MappingNode:
+			if i%2 == 1 {
+				parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+				return true
+			}
+		case yaml.SequenceNode:
+			parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+			return true
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const danglingRefsSpec = `paths:
+  /drinks:
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Alias"
+        "404":
+          $ref: "#/components/responses/Missing"
+  /orders:
+    get:
+      parameters:
+        - $ref: "#/components/parameters/Drink"
+        - name: limit
+          in: query
+components:
+  parameters:
+    Drink:
+      name: drink
+      in: query
+      schema:
+        $ref: "#/components/schemas/Drink/properties/type"
+  schemas:
+    Alias:
+      $ref: "#/components/schemas/Drink"
+    Drink:
+      properties:
+        type:
+          type: string
+`
+
+func applyWithDanglingRefs(t *testing.T, policy overlay.DanglingRefPolicy, actions string) (*overlay.ApplyResult, string) {
+	t.Helper()
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(danglingRefsSpec), &node))
+
+	var o overlay.Overlay
+	err := yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Dangling refs
+  version: 0.0.0
+actions:
+`+actions), &o)
+	require.NoError(t, err)
+
+	result, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{DanglingRefs: policy})
+	require.NoError(t, err)
+
+	out, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	return result, string(out)
+}
+
+const removeDrink = `
+  - target: $.components.schemas.Drink
+    remove: true
+`
+
+func TestApplyToContext_DanglingRefsIgnore(t *testing.T) {
+	t.Parallel()
+
+	result, out := applyWithDanglingRefs(t, "", removeDrink)
+	assert.Empty(t, result.DanglingRefs)
+	assert.Contains(t, out, `$ref: "#/components/schemas/Drink"`)
+}
+
+func TestApplyToContext_DanglingRefsReport(t *testing.T) {
+	t.Parallel()
+
+	result, out := applyWithDanglingRefs(t, overlay.DanglingRefsReport, removeDrink)
+	assert.Equal(t, []overlay.DanglingRef{
+		{Ref: "#/components/schemas/Drink/properties/type", Path: "#/components/parameters/Drink/schema"},
+		{Ref: "#/components/schemas/Drink", Path: "#/components/schemas/Alias"},
+	}, result.DanglingRefs, "refs that were already dangling should not be reported")
+	assert.Contains(t, out, `$ref: "#/components/schemas/Drink"`)
+}
+
+func TestApplyToContext_DanglingRefsRemove(t *testing.T) {
+	t.Parallel()
+
+	result, out := applyWithDanglingRefs(t, overlay.DanglingRefsRemove, removeDrink)
+	assert.Equal(t, []overlay.DanglingRef{
+		{Ref: "#/components/schemas/Drink/properties/type", Path: "#/components/parameters/Drink/schema", Removed: true},
+		{Ref: "#/components/schemas/Drink", Path: "#/components/schemas/Alias", Removed: true},
+		{Ref: "#/components/schemas/Alias", Path: "#/paths/~1drinks/get/responses/200/content/application~1json/schema", Removed: true},
+	}, result.DanglingRefs)
+	assert.Equal(t, `paths:
+    /drinks:
+        get:
+            responses:
+                "200":
+                    content:
+                        application/json: {}
+                "404":
+                    $ref: "#/components/responses/Missing"
+    /orders:
+        get:
+            parameters:
+                - $ref: "#/components/parameters/Drink"
+                - name: limit
+                  in: query
+components:
+    parameters:
+        Drink:
+            name: drink
+            in: query
+    schemas: {}
+`, out)
+
+	result, out = applyWithDanglingRefs(t, overlay.DanglingRefsRemove, `
+  - target: $.components.parameters.Drink
+    remove: true
+`)
+	assert.Equal(t, []overlay.DanglingRef{
+		{Ref: "#/components/parameters/Drink", Path: "#/paths/~1orders/get/parameters/0", Removed: true},
+	}, result.DanglingRefs)
+	assert.Contains(t, out, "parameters:\n                - name: limit\n")
+}
+
+func TestApplyToContext_DanglingRefsRewrite(t *testing.T) {
+	t.Parallel()
+
+	result, out := applyWithDanglingRefs(t, overlay.DanglingRefsRewrite, `
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename: Beverage
+  - target: $.components.schemas.Alias
+    remove: true
+`)
+	assert.Equal(t, []overlay.DanglingRef{
+		{Ref: "#/components/schemas/Alias", Path: "#/paths/~1drinks/get/responses/200/content/application~1json/schema"},
+		{Ref: "#/components/schemas/Drink/properties/type", Path: "#/components/parameters/Drink/schema", NewRef: "#/components/schemas/Beverage/properties/type"},
+	}, result.DanglingRefs)
+	assert.Contains(t, out, `$ref: "#/components/schemas/Beverage/properties/type"`)
+	assert.Contains(t, out, `$ref: "#/components/schemas/Alias"`, "refs to removed nodes can only be reported")
+}
+
+func TestApplyToContext_UnknownDanglingRefPolicy(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	_, err := o.ApplyToContext(context.Background(), &yaml.Node{}, &overlay.ApplyOptions{DanglingRefs: "fix"})
+	assert.ErrorContains(t, err, `unknown dangling ref policy "fix"`)
+}
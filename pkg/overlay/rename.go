@@ -0,0 +1,114 @@
+package overlay
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RenameExtension is the action field that renames the mapping keys of the
+// nodes selected by the action's target, keeping their values, position and
+// comments. Its value is either the new key:
+//
+//	actions:
+//	  - target: $.paths["/v1/drinks"]
+//	    x-speakeasy-rename: /drinks
+//
+// or a mapping that can also ask for local $refs to the renamed node, and to
+// anything below it, to be rewritten:
+//
+//	actions:
+//	  - target: $.components.schemas.Drink
+//	    x-speakeasy-rename:
+//	      to: Beverage
+//	      updateRefs: true
+const RenameExtension = "x-speakeasy-rename"
+
+type renameOptions struct {
+	To         string `yaml:"to"`
+	UpdateRefs bool   `yaml:"updateRefs"`
+}
+
+func init() {
+	err := RegisterCustomAction(RenameExtension, CustomAction{
+		Validate: func(value any) error {
+			_, err := parseRenameOptions(value)
+			return err
+		},
+		Apply: applyRename,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func parseRenameOptions(value any) (renameOptions, error) {
+	var opts renameOptions
+	switch v := value.(type) {
+	case string:
+		opts.To = v
+	case map[string]any:
+		var node yaml.Node
+		if err := node.Encode(v); err != nil {
+			return opts, err
+		}
+		if err := node.Decode(&opts); err != nil {
+			return opts, err
+		}
+		for key := range v {
+			if key != "to" && key != "updateRefs" {
+				return opts, fmt.Errorf("unknown field %q, expected to or updateRefs", key)
+			}
+		}
+	default:
+		return opts, fmt.Errorf("expected the new key or a mapping with to and updateRefs")
+	}
+
+	if opts.To == "" {
+		return opts, fmt.Errorf("the new key must not be empty")
+	}
+	return opts, nil
+}
+
+func applyRename(ctx *CustomActionContext) error {
+	opts, err := parseRenameOptions(ctx.Value)
+	if err != nil {
+		return err
+	}
+
+	parent := ctx.Parent
+	if parent == nil || parent.Kind != yaml.MappingNode {
+		return fmt.Errorf("only mapping entries can be renamed")
+	}
+
+	i := ctx.idx.position(parent, ctx.Node)
+	if i < 0 {
+		return nil
+	}
+	key := parent.Content[i-i%2]
+	if key.Value == opts.To {
+		return nil
+	}
+
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i] != key && parent.Content[i].Value == opts.To {
+			return fmt.Errorf("cannot rename %q to %q: the key already exists", key.Value, opts.To)
+		}
+	}
+
+	var oldPath simplePath
+	if opts.UpdateRefs {
+		oldPath = ctx.idx.pathTo(key)
+	}
+
+	key.Value = opts.To
+	ctx.touch(parent.Content[i-i%2+1])
+
+	if opts.UpdateRefs {
+		dir := oldPath.Dir()
+		newPath := append(dir[:len(dir):len(dir)], keyPart(opts.To))
+		rewriteRefs(ctx.Root, oldPath.ToJSONPointer(), newPath.ToJSONPointer())
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package overlay_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const renameSpec = `paths:
+  /v1/drinks: # list of drinks
+    get:
+      responses:
+        "200":
+          $ref: "#/components/responses/Drinks"
+  /v1/orders:
+    post: {}
+components:
+  responses:
+    Drinks:
+      content:
+        application/json:
+          schema:
+            $ref: "#/components/schemas/Drink"
+  schemas:
+    Drink:
+      properties:
+        type:
+          type: string
+    DrinkType:
+      type: string
+    Order:
+      properties:
+        drinkType:
+          $ref: "#/components/schemas/Drink/properties/type"
+        other:
+          $ref: "#/components/schemas/DrinkType"
+`
+
+func applyRenameOverlay(t *testing.T, actions string) (string, error) {
+	t.Helper()
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(renameSpec), &node))
+
+	var o overlay.Overlay
+	err := yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Rename
+  version: 0.0.0
+actions:
+`+actions), &o)
+	require.NoError(t, err)
+	require.NoError(t, o.Validate())
+
+	if err := o.ApplyTo(&node); err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(&node)
+	require.NoError(t, err)
+	return string(out), nil
+}
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	out, err := applyRenameOverlay(t, `
+  - target: $.paths["/v1/drinks"]
+    x-speakeasy-rename: /drinks
+`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "paths:\n    /drinks: # list of drinks\n        get:")
+	assert.Less(t, strings.Index(out, "/drinks:"), strings.Index(out, "/v1/orders:"), "the renamed key should keep its position")
+	assert.NotContains(t, out, "/v1/drinks")
+}
+
+func TestRename_UpdateRefs(t *testing.T) {
+	t.Parallel()
+
+	out, err := applyRenameOverlay(t, `
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename:
+      to: Beverage
+      updateRefs: true
+`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "        Beverage:\n")
+	assert.Contains(t, out, `$ref: "#/components/schemas/Beverage"`)
+	assert.Contains(t, out, `$ref: "#/components/schemas/Beverage/properties/type"`)
+	assert.Contains(t, out, `$ref: "#/components/schemas/DrinkType"`, "refs that only share a prefix should not be rewritten")
+	assert.NotContains(t, out, `schemas/Drink"`)
+
+	out, err = applyRenameOverlay(t, `
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename: Beverage
+`)
+	require.NoError(t, err)
+	assert.Contains(t, out, `$ref: "#/components/schemas/Drink"`, "refs should only be rewritten when asked to")
+}
+
+func TestRename_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := applyRenameOverlay(t, `
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename: DrinkType
+`)
+	assert.ErrorContains(t, err, `cannot rename "Drink" to "DrinkType": the key already exists`)
+
+	_, err = applyRenameOverlay(t, `
+  - target: $.components.schemas.Drink.required
+    x-speakeasy-rename: mandatory
+`)
+	require.NoError(t, err, "targets that match nothing are ignored")
+
+	var o overlay.Overlay
+	err = yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+info:
+  title: Rename
+  version: 0.0.0
+actions:
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename: ""
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename:
+      to: Beverage
+      refs: true
+  - target: $.components.schemas.Drink
+    x-speakeasy-rename: [Beverage]
+`), &o)
+	require.NoError(t, err)
+	err = o.Validate()
+	assert.ErrorContains(t, err, "overlay action at index 0 has an invalid x-speakeasy-rename: the new key must not be empty")
+	assert.ErrorContains(t, err, `overlay action at index 1 has an invalid x-speakeasy-rename: unknown field "refs", expected to or updateRefs`)
+	assert.ErrorContains(t, err, "overlay action at index 2 has an invalid x-speakeasy-rename: expected the new key or a mapping with to and updateRefs")
+}
@@ -25,6 +25,11 @@ type Overlay struct {
 	// Extends is a URL to the OpenAPI specification this overlay applies to.
 	Extends string `yaml:"extends,omitempty"`
 
+	// Document is the index of the document the overlay applies to when the
+	// specification is a YAML stream of several documents. It can be left out
+	// when there is only one.
+	Document *int `yaml:"x-speakeasy-document,omitempty"`
+
 	// Actions is the list of actions to perform to apply the overlay.
 	Actions []Action `yaml:"actions"`
 }
@@ -63,4 +68,18 @@ type Action struct {
 
 	// Remove marks the target node for removal rather than update.
 	Remove bool `yaml:"remove,omitempty"`
+
+	// line is the line of the action in the overlay file, if it was parsed
+	// from one.
+	line int
+}
+
+// UnmarshalYAML records the line of the action along with its fields.
+func (a *Action) UnmarshalYAML(value *yaml.Node) error {
+	type plain Action
+	if err := value.Decode((*plain)(a)); err != nil {
+		return err
+	}
+	a.line = value.Line
+	return nil
 }
@@ -0,0 +1,215 @@
+package overlay
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSplitGroup is the group that actions are placed in when a SplitFunc
+// cannot associate them with any other group. The built-in SplitFuncs never
+// use it for any other group: one that would be named the same gets a
+// "-group" suffix instead.
+const DefaultSplitGroup = "common"
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SplitFunc returns the name of the group the given action of the overlay
+// belongs to.
+type SplitFunc func(o *Overlay, action Action) (string, error)
+
+// SplitGroup is one of the overlays produced by Split.
+type SplitGroup struct {
+	Name    string
+	Overlay *Overlay
+}
+
+// Split breaks the overlay up into several overlays, grouping its actions with
+// the given function. Groups are returned in the order they are first seen, and
+// actions keep their relative order. Each resulting overlay carries the info,
+// extends and extensions of the original.
+func (o *Overlay) Split(groupBy SplitFunc) ([]SplitGroup, error) {
+	var groups []SplitGroup
+	groupIndex := map[string]int{}
+	for _, action := range o.Actions {
+		name, err := groupBy(o, action)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := groupIndex[name]
+		if !ok {
+			i = len(groups)
+			groupIndex[name] = i
+			groups = append(groups, SplitGroup{
+				Name: name,
+				Overlay: &Overlay{
+					Extensions:      o.Extensions,
+					Version:         o.Version,
+					JSONPathVersion: o.JSONPathVersion,
+					Info:            o.Info,
+					Extends:         o.Extends,
+					Document:        o.Document,
+				},
+			})
+		}
+		groups[i].Overlay.Actions = append(groups[i].Overlay.Actions, action)
+	}
+
+	return groups, nil
+}
+
+// SplitByPathPrefix groups actions by the first segment of the path they
+// target, so that actions on $.paths["/drinks"] and $.paths["/drinks/{name}"]
+// both end up in the "drinks" group. Actions that don't target a specific path
+// are placed in the DefaultSplitGroup.
+func SplitByPathPrefix() SplitFunc {
+	return func(o *Overlay, action Action) (string, error) {
+		path, _ := leadingSimplePath(action.Target)
+		if len(path) < 2 || path[0] != keyPart("paths") || !path[1].isKey {
+			return DefaultSplitGroup, nil
+		}
+
+		segment, _, _ := strings.Cut(strings.TrimPrefix(path[1].key, "/"), "/")
+		if segment == "" {
+			return DefaultSplitGroup, nil
+		}
+		return groupName(segment), nil
+	}
+}
+
+// SplitByTag groups actions by the first tag of the operation they target in
+// the given specification. Actions targeting a path item use the tags of its
+// first tagged operation. Actions that don't target a tagged operation are
+// placed in the DefaultSplitGroup.
+func SplitByTag(root *yaml.Node) SplitFunc {
+	idx := newParentIndex(root)
+	return func(o *Overlay, action Action) (string, error) {
+		nodes, err := o.queryTarget(root, action)
+		if err != nil {
+			return "", err
+		}
+
+		for _, node := range nodes {
+			path := idx.pathTo(node)
+			if len(path) < 2 || path[0] != keyPart("paths") {
+				continue
+			}
+
+			var operations []*yaml.Node
+			if len(path) >= 3 {
+				operations = append(operations, path[:3].Resolve(root))
+			} else if pathItem := path[:2].Resolve(root); pathItem != nil && pathItem.Kind == yaml.MappingNode {
+				for i := 1; i < len(pathItem.Content); i += 2 {
+					operations = append(operations, pathItem.Content[i])
+				}
+			}
+
+			for _, operation := range operations {
+				if tag := firstTag(operation); tag != "" {
+					return groupName(tag), nil
+				}
+			}
+		}
+
+		return DefaultSplitGroup, nil
+	}
+}
+
+// SplitByJSONPath groups actions by the nodes selected by the given JSONPath
+// expression in the specification. An action belongs to the first selected
+// node that contains one of the nodes it targets, and the group is named after
+// that node's key or index. For example, $.components.schemas.* groups actions
+// by schema name. Other actions are placed in the DefaultSplitGroup.
+func SplitByJSONPath(root *yaml.Node, expression string) SplitFunc {
+	idx := newParentIndex(root)
+	var groupPaths []simplePath
+	return func(o *Overlay, action Action) (string, error) {
+		if groupPaths == nil {
+			p, err := o.NewPath(expression, nil)
+			if err != nil {
+				return "", err
+			}
+
+			groupPaths = []simplePath{}
+			for _, node := range p.Query(root) {
+				groupPaths = append(groupPaths, idx.pathTo(node))
+			}
+		}
+
+		nodes, err := o.queryTarget(root, action)
+		if err != nil {
+			return "", err
+		}
+
+		for _, node := range nodes {
+			path := idx.pathTo(node)
+			for _, groupPath := range groupPaths {
+				if len(groupPath) == 0 || !path.HasPrefix(groupPath) {
+					continue
+				}
+				base := groupPath.Base()
+				if base.isKey {
+					return groupName(base.key), nil
+				}
+				return strconv.Itoa(base.index), nil
+			}
+		}
+
+		return DefaultSplitGroup, nil
+	}
+}
+
+// SplitFileNames returns a file name, without an extension, for each of the
+// groups, made of the characters of its name that are safe in file names.
+// Groups whose names come out the same, ignoring case, are told apart by a
+// numeric suffix, and only the DefaultSplitGroup is given its name.
+func SplitFileNames(groups []SplitGroup) []string {
+	used := map[string]bool{DefaultSplitGroup: true}
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		if group.Name == DefaultSplitGroup {
+			names[i] = DefaultSplitGroup
+			continue
+		}
+
+		base := strings.Trim(unsafeFileNameChars.ReplaceAllString(group.Name, "-"), "-")
+		if base == "" {
+			base = "group"
+		}
+		name := base
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = base + "-" + strconv.Itoa(n)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// groupName keeps a group found by a SplitFunc from being merged into the
+// DefaultSplitGroup.
+func groupName(name string) string {
+	if name == DefaultSplitGroup {
+		return name + "-group"
+	}
+	return name
+}
+
+func firstTag(operation *yaml.Node) string {
+	if operation == nil || operation.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(operation.Content); i += 2 {
+		if operation.Content[i].Value != "tags" {
+			continue
+		}
+		tags := operation.Content[i+1]
+		if tags.Kind == yaml.SequenceNode && len(tags.Content) > 0 {
+			return tags.Content[0].Value
+		}
+	}
+	return ""
+}
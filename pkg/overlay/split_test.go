@@ -0,0 +1,120 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// groupTargets summarises split groups as a map of group name to the targets of
+// the actions in the group.
+func groupTargets(groups []overlay.SplitGroup) map[string][]string {
+	out := map[string][]string{}
+	for _, group := range groups {
+		for _, action := range group.Overlay.Actions {
+			out[group.Name] = append(out[group.Name], action.Target)
+		}
+	}
+	return out
+}
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		groupBy  overlay.SplitFunc
+		expected map[string][]string
+	}{
+		{
+			name:    "path prefix",
+			groupBy: overlay.SplitByPathPrefix(),
+			expected: map[string][]string{
+				"drink":    {`$.paths["/drink/{name}"].get`, `$.paths["/drink/{name}"].get`},
+				"drinks":   {`$.paths["/drinks"].get`, `$.paths["/drinks"]`},
+				"common":   {`$.tags`, `$.tags[?(@.name == "authentication")].description~`},
+				"anything": {`$.paths["/anything/selectGlobalServer"]["x-my-ignore"]`},
+			},
+		},
+		{
+			name:    "tag",
+			groupBy: overlay.SplitByTag(node),
+			expected: map[string][]string{
+				"drinks": {`$.paths["/drink/{name}"].get`, `$.paths["/drinks"].get`, `$.paths["/drinks"]`, `$.paths["/drink/{name}"].get`},
+				"common": {`$.tags`, `$.tags[?(@.name == "authentication")].description~`, `$.paths["/anything/selectGlobalServer"]["x-my-ignore"]`},
+			},
+		},
+		{
+			name:    "jsonpath",
+			groupBy: overlay.SplitByJSONPath(node, `$.paths.*`),
+			expected: map[string][]string{
+				"/drink/{name}":                {`$.paths["/drink/{name}"].get`, `$.paths["/drink/{name}"].get`},
+				"/drinks":                      {`$.paths["/drinks"].get`, `$.paths["/drinks"]`},
+				"common":                       {`$.tags`, `$.tags[?(@.name == "authentication")].description~`},
+				"/anything/selectGlobalServer": {`$.paths["/anything/selectGlobalServer"]["x-my-ignore"]`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := o.Split(tt.groupBy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, groupTargets(groups))
+
+			for _, group := range groups {
+				assert.Equal(t, o.Info, group.Overlay.Info)
+				assert.Equal(t, o.Extends, group.Overlay.Extends)
+				assert.NoError(t, group.Overlay.Validate())
+			}
+		})
+	}
+}
+
+func TestSplitFileNames(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Colliding groups
+  version: 1.0.0
+actions:
+  - target: $.paths["/a b"]
+    remove: true
+  - target: $.paths["/a-b"]
+    remove: true
+  - target: $.paths["/A-B"]
+    remove: true
+  - target: $.paths["/common"]
+    remove: true
+  - target: $.tags
+    remove: true
+  - target: $.paths["/???"]
+    remove: true
+`), &o))
+
+	groups, err := o.Split(overlay.SplitByPathPrefix())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"a b":          {`$.paths["/a b"]`},
+		"a-b":          {`$.paths["/a-b"]`},
+		"A-B":          {`$.paths["/A-B"]`},
+		"common-group": {`$.paths["/common"]`},
+		"common":       {`$.tags`},
+		"???":          {`$.paths["/???"]`},
+	}, groupTargets(groups), "a path named common should not be merged into the default group")
+	assert.Equal(t, []string{"a-b", "a-b-2", "A-B-3", "common-group", "common", "group"}, overlay.SplitFileNames(groups))
+}
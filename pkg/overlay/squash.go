@@ -0,0 +1,147 @@
+package overlay
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Squash rewrites the overlay into the minimal list of actions that has the
+// same effect on the given specification. Redundant actions, such as updates
+// that are later removed or repeated updates to the same target, are dropped.
+//
+// The effective change is computed by applying the overlay to a copy of the
+// specification and comparing the result with the original. Surviving actions
+// keep the description and extensions of the last original action that touched
+// the same part of the document. The given specification is not modified.
+//
+// Actions with an x-when condition depend on the variables the overlay is
+// applied with, so they are kept as they are, and only the actions between
+// them are squashed together. The squashed overlay uses RFC 9535 JSONPath, so,
+// as with Merge, the kept actions of an overlay that does not must have
+// targets that mean the same under it.
+func (o *Overlay) Squash(root *yaml.Node) (*Overlay, error) {
+	working := clone(root)
+	var actions []Action
+	start := 0
+	for i := 0; i <= len(o.Actions); i++ {
+		if i < len(o.Actions) {
+			if _, conditional := o.Actions[i].Extensions[WhenExtension]; !conditional {
+				continue
+			}
+		}
+
+		squashed, err := o.squashActions(working, o.Actions[start:i])
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, squashed...)
+		if i < len(o.Actions) {
+			if !o.UsesRFC9535() {
+				if err := checkUpgradableTarget(o.Actions[i].Target); err != nil {
+					return nil, fmt.Errorf("overlay action at index %d cannot be upgraded to rfc9535 jsonpath: %w", i, err)
+				}
+			}
+			actions = append(actions, o.Actions[i])
+		}
+		start = i + 1
+	}
+
+	return &Overlay{
+		Extensions:      o.Extensions,
+		Version:         o.Version,
+		JSONPathVersion: "rfc9535",
+		Info:            o.Info,
+		Extends:         o.Extends,
+		Document:        o.Document,
+		Actions:         actions,
+	}, nil
+}
+
+// squashActions applies the actions to the working document and returns the
+// minimal list of actions with the same effect on it.
+func (o *Overlay) squashActions(working *yaml.Node, original []Action) ([]Action, error) {
+	if len(original) == 0 {
+		return nil, nil
+	}
+
+	before := clone(working)
+	state := newApplyState(working)
+	touched := make([][]string, len(original))
+	for i, action := range original {
+		paths, err := o.matchedPaths(state, action)
+		if err != nil {
+			return nil, err
+		}
+		touched[i] = paths
+
+		if err := o.applyAction(state, action); err != nil {
+			return nil, err
+		}
+	}
+
+	compared, err := Compare(o.Info.Title, before, *working)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range compared.Actions {
+		origin := findSquashOrigin(original, touched, compared.Actions[i])
+		if origin == nil {
+			continue
+		}
+		compared.Actions[i].Description = origin.Description
+		if len(origin.Extensions) > 0 {
+			compared.Actions[i].Extensions = make(Extensions, len(origin.Extensions))
+			for k, v := range origin.Extensions {
+				compared.Actions[i].Extensions[k] = v
+			}
+		}
+	}
+	return compared.Actions, nil
+}
+
+// matchedPaths returns the normalized paths of every node the action's target
+// selects in the given document.
+func (o *Overlay) matchedPaths(state *applyState, action Action) ([]string, error) {
+	nodes, err := o.queryTarget(state.root, action)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+
+	idx := state.parents()
+	paths := make([]string, len(nodes))
+	for i, node := range nodes {
+		paths[i] = idx.pathTo(node).ToJSONPath()
+	}
+	return paths, nil
+}
+
+// findSquashOrigin returns the last original action that touched the target of
+// the squashed action or one of its ancestors. Actions of the same kind
+// (update or remove) are preferred.
+func findSquashOrigin(actions []Action, touched [][]string, squashed Action) *Action {
+	var fallback *Action
+	for i := len(actions) - 1; i >= 0; i-- {
+		if !touchesTarget(touched[i], squashed.Target) {
+			continue
+		}
+		if actions[i].Remove == squashed.Remove {
+			return &actions[i]
+		}
+		if fallback == nil {
+			fallback = &actions[i]
+		}
+	}
+	return fallback
+}
+
+func touchesTarget(paths []string, target string) bool {
+	for _, path := range paths {
+		if target == path || strings.HasPrefix(target, path+"[") {
+			return true
+		}
+	}
+	return false
+}
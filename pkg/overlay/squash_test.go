@@ -0,0 +1,129 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/loader"
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSquash(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay-redundant.yaml")
+	require.NoError(t, err)
+
+	squashed, err := o.Squash(node)
+	require.NoError(t, err)
+
+	assert.Equal(t, o.Info, squashed.Info)
+	require.Len(t, squashed.Actions, 1)
+	assert.Equal(t, `$["paths"]["/drink/{name}"]["get"]["summary"]`, squashed.Actions[0].Target)
+	assert.Equal(t, "Fetch a drink.", squashed.Actions[0].Update.Value)
+	assert.Equal(t, "Final summary", squashed.Actions[0].Description)
+	assert.Equal(t, "drinks", squashed.Actions[0].Extensions["x-team"])
+
+	// the spec is left untouched
+	original, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, original, node)
+}
+
+func TestSquash_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	o, err := loader.LoadOverlay("testdata/overlay.yaml")
+	require.NoError(t, err)
+
+	squashed, err := o.Squash(node)
+	require.NoError(t, err)
+
+	err = squashed.ApplyTo(node)
+	require.NoError(t, err)
+
+	NodeMatchesFile(t, node, "testdata/openapi-overlayed.yaml")
+}
+
+func TestSquash_When(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(serversOverlay), &o))
+
+	squashed, err := o.Squash(node)
+	require.NoError(t, err)
+
+	// the conditional actions are kept as they are, and the rest squashed
+	require.Len(t, squashed.Actions, 3)
+	assert.Equal(t, o.Actions[0], squashed.Actions[0])
+	assert.Equal(t, o.Actions[1], squashed.Actions[1])
+	assert.Equal(t, `$["info"]["title"]`, squashed.Actions[2].Target)
+	assert.Equal(t, "Drinks", squashed.Actions[2].Update.Value)
+
+	_, err = squashed.ApplyToContext(context.Background(), node, &overlay.ApplyOptions{Vars: map[string]string{"env": "prod"}})
+	require.NoError(t, err)
+	var doc struct {
+		Servers []struct {
+			URL string `yaml:"url"`
+		} `yaml:"servers"`
+	}
+	require.NoError(t, node.Decode(&doc))
+	assert.Equal(t, "https://api.example.com", doc.Servers[0].URL)
+}
+
+func TestSquash_WhenLegacy(t *testing.T) {
+	t.Parallel()
+
+	node, err := loader.LoadSpecification("testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+info:
+  title: Legacy servers
+  version: 0.0.0
+actions:
+  - target: $.servers[0]
+    x-when:
+      env: prod
+    update:
+      url: https://api.example.com
+  - target: $.info
+    update:
+      title: Drinks
+`), &o))
+
+	squashed, err := o.Squash(node)
+	require.NoError(t, err)
+	assert.True(t, squashed.UsesRFC9535())
+	assert.Equal(t, o.Actions[0], squashed.Actions[0], "targets that mean the same under rfc9535 should be kept")
+
+	require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+info:
+  title: Legacy tags
+  version: 0.0.0
+actions:
+  - target: $.tags[?(@.name == "authentication")]
+    x-when:
+      env: prod
+    update:
+      description: Sign in
+`), &o))
+	_, err = o.Squash(node)
+	assert.EqualError(t, err, `overlay action at index 0 cannot be upgraded to rfc9535 jsonpath: target "$.tags[?(@.name == \"authentication\")]" uses a filter expression`)
+}
@@ -43,6 +43,10 @@ func (o *Overlay) Validate() error {
 		}
 	}
 
+	if o.Document != nil && *o.Document < 0 {
+		errs = append(errs, fmt.Errorf("overlay x-speakeasy-document must not be negative"))
+	}
+
 	if len(o.Actions) == 0 {
 		errs = append(errs, fmt.Errorf("overlay must define at least one action"))
 	} else {
@@ -54,6 +58,13 @@ func (o *Overlay) Validate() error {
 			if action.Remove && !action.Update.IsZero() {
 				errs = append(errs, fmt.Errorf("overlay action at index %d should not both set remove and define update", i))
 			}
+
+			if _, err := actionCondition(action); err != nil {
+				errs = append(errs, fmt.Errorf("overlay action at index %d x-when is invalid: %w", i, err))
+			}
+
+			errs = append(errs, validateMatchExtensions(i, action)...)
+			errs = append(errs, validateCustomActions(i, action)...)
 		}
 	}
 
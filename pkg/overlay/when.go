@@ -0,0 +1,122 @@
+package overlay
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// WhenExtension is the action field that makes an action depend on the
+// variables the overlay is applied with, set by ApplyOptions.Vars. The action
+// is skipped unless each variable named has the given value, or one of the
+// given values:
+//
+//	actions:
+//	  - target: $.servers
+//	    x-when:
+//	      env: prod
+//	    update:
+//	      - url: https://api.example.com
+//	  - target: $.servers
+//	    x-when:
+//	      env: [staging, dev]
+//	    update:
+//	      - url: https://staging.example.com
+//
+// Every variable named must be set when the overlay is applied with variables.
+// Without any, such as with ApplyTo, actions with an x-when are skipped.
+const WhenExtension = "x-when"
+
+// condition maps the variables named by x-when to their accepted values.
+type condition map[string][]string
+
+func parseCondition(value any) (condition, error) {
+	vars, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping of variable names to values")
+	}
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("expected at least one variable")
+	}
+
+	c := condition{}
+	for name, v := range vars {
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("variable %q has no values", name)
+		}
+		for _, value := range values {
+			switch value.(type) {
+			case string, bool, int, int64, uint64, float64:
+				c[name] = append(c[name], fmt.Sprint(value))
+			default:
+				return nil, fmt.Errorf("variable %q must be compared to a string, number or boolean", name)
+			}
+		}
+	}
+	return c, nil
+}
+
+// actionCondition returns the action's x-when condition, or nil if it has none.
+func actionCondition(action Action) (condition, error) {
+	value, ok := action.Extensions[WhenExtension]
+	if !ok {
+		return nil, nil
+	}
+	return parseCondition(value)
+}
+
+// compatible reports whether both conditions can hold at once, which they
+// cannot if they accept no common value for a variable they both name. A nil
+// condition is compatible with any other.
+func (c condition) compatible(other condition) bool {
+	for name, values := range c {
+		accepted, ok := other[name]
+		if !ok {
+			continue
+		}
+		if !slices.ContainsFunc(values, func(value string) bool { return slices.Contains(accepted, value) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// holds reports whether every variable has one of the accepted values.
+func (c condition) holds(vars map[string]string) (bool, error) {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	holds := true
+	for _, name := range names {
+		value, ok := vars[name]
+		if !ok {
+			return false, fmt.Errorf("x-when refers to variable %q, which is not set", name)
+		}
+		if !slices.Contains(c[name], value) {
+			holds = false
+		}
+	}
+	return holds, nil
+}
+
+// SkippedAction is an action that was not applied as its x-when condition did
+// not hold.
+type SkippedAction struct {
+	// Index is the index of the action in the overlay.
+	Index       int    `json:"index"`
+	Description string `json:"description,omitempty"`
+}
+
+func (s SkippedAction) String() string {
+	if s.Description == "" {
+		return fmt.Sprintf("actions[%d] was skipped, as its x-when condition does not hold", s.Index)
+	}
+	return fmt.Sprintf("actions[%d] %q was skipped, as its x-when condition does not hold", s.Index, s.Description)
+}
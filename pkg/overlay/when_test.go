@@ -0,0 +1,143 @@
+package overlay_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/speakeasy-api/openapi-overlay/pkg/overlay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const whenOverlay = `
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Environments
+  version: 0.0.0
+actions:
+  - target: $.info
+    description: Production title
+    x-when:
+      env: prod
+    update:
+      title: Drinks
+  - target: $.info
+    x-when:
+      env: [staging, dev]
+    update:
+      title: Drinks (test)
+  - target: $.info
+    x-when:
+      env: prod
+      beta: true
+    update:
+      x-beta: true
+`
+
+// serversOverlay sets the first server for each environment, as in the README.
+const serversOverlay = `
+overlay: 1.0.0
+x-speakeasy-jsonpath: rfc9535
+info:
+  title: Servers
+  version: 0.0.0
+actions:
+  - target: $.servers[0]
+    x-when:
+      env: prod
+    update:
+      url: https://api.example.com
+  - target: $.servers[0]
+    x-when:
+      env: staging
+    update:
+      url: https://staging.example.com
+  - target: $.info
+    update:
+      title: Draft
+  - target: $.info
+    update:
+      title: Drinks
+`
+
+func applyWhenOverlay(t *testing.T, vars map[string]string) (map[string]any, *overlay.ApplyResult, []overlay.ActionEvent) {
+	t.Helper()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(whenOverlay), &o))
+	require.NoError(t, o.Validate())
+
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+
+	var events []overlay.ActionEvent
+	result, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{
+		Vars:     vars,
+		Observer: func(event overlay.ActionEvent) { events = append(events, event) },
+	})
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, node.Decode(&doc))
+	return doc["info"].(map[string]any), result, events
+}
+
+func TestWhen(t *testing.T) {
+	t.Parallel()
+
+	info, result, events := applyWhenOverlay(t, map[string]string{"env": "prod", "beta": "true"})
+	assert.Equal(t, map[string]any{"title": "Drinks", "x-beta": true}, info)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, overlay.SkippedAction{Index: 1}, result.Skipped[0])
+	assert.Equal(t, "actions[1] was skipped, as its x-when condition does not hold", result.Skipped[0].String())
+	assert.Equal(t, overlay.ActionSkipped, events[2].Kind)
+	assert.Len(t, events, 5, "a skipped action should only report that it was skipped")
+
+	info, result, _ = applyWhenOverlay(t, map[string]string{"env": "dev", "beta": "false"})
+	assert.Equal(t, map[string]any{"title": "Drinks (test)"}, info)
+	require.Len(t, result.Skipped, 2)
+	assert.Equal(t, `actions[0] "Production title" was skipped, as its x-when condition does not hold`, result.Skipped[0].String())
+}
+
+func TestWhen_WithoutVars(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(whenOverlay), &o))
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+
+	require.NoError(t, o.ApplyTo(&node))
+	var doc map[string]any
+	require.NoError(t, node.Decode(&doc))
+	assert.Equal(t, map[string]any{"info": map[string]any{"title": "Bar"}}, doc)
+}
+
+func TestWhen_Errors(t *testing.T) {
+	t.Parallel()
+
+	var o overlay.Overlay
+	require.NoError(t, yaml.Unmarshal([]byte(whenOverlay), &o))
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("info: {title: Bar}\n"), &node))
+
+	_, err := o.ApplyToContext(context.Background(), &node, &overlay.ApplyOptions{Vars: map[string]string{"env": "prod"}})
+	assert.EqualError(t, err, `overlay action at index 2: x-when refers to variable "beta", which is not set`)
+
+	for _, when := range []string{`prod`, `{}`, `{env: []}`, `{env: {nested: true}}`} {
+		var o overlay.Overlay
+		require.NoError(t, yaml.Unmarshal([]byte(`
+overlay: 1.0.0
+info: {title: Invalid, version: 0.0.0}
+actions:
+  - target: $.info
+    x-when: `+when+`
+    update: {title: Drinks}
+`), &o))
+		assert.ErrorContains(t, o.Validate(), "overlay action at index 0 x-when is invalid", when)
+		_, err := o.Compile()
+		assert.ErrorContains(t, err, "overlay action at index 0 x-when is invalid", when)
+	}
+}